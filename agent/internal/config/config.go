@@ -16,15 +16,54 @@ const (
 	defaultPollInterval   = 500 * time.Millisecond
 	defaultLogLevel       = "info"
 	defaultWorkerLimit    = 16
+	defaultStatsMode      = "poll"
+	defaultReconcile      = 30 * time.Second
+	defaultHostProc       = "/proc"
+	defaultHostRoot       = "/"
+	defaultSource         = "docker"
+	defaultCgroupRoot     = "/sys/fs/cgroup"
+	defaultSimContainers  = 12
+	defaultDeltaEpsilon   = 0.01
+	defaultKeyframe       = 30 * time.Second
+	defaultReplayBuffer   = 120
+	defaultSlowPolicy     = "coalesce"
+	defaultClientQueue    = 64
+	defaultCompressThresh = 1024
 )
 
 type Config struct {
-	DockerEndpoint string
-	ListenAddr     string
-	HostLabel      string
-	PollInterval   time.Duration
-	LogLevel       string
-	WorkerLimit    int
+	DockerEndpoint    string
+	ListenAddr        string
+	HostLabel         string
+	PollInterval      time.Duration
+	LogLevel          string
+	WorkerLimit       int
+	NetPerInterface   bool
+	StatsMode         string
+	ReconcileInterval time.Duration
+	LabelAllowlist    []string
+	ComposeRollups    bool
+	GroupByLabels     []string
+	HostMetrics       bool
+	HostProc          string
+	HostRoot          string
+	Source            string
+	CgroupRoot        string
+	FlushNew          bool
+	DeltaEpsilon      float64
+	KeyframeInterval  time.Duration
+	ReplayBuffer      int
+	SlowClientPolicy  string
+	ClientQueue       int
+	CompressionLevel  int
+	CompressThreshold int
+	TLSCert           string
+	TLSKey            string
+	TLSClientCA       string
+	AuthTokensFile    string
+
+	SimulateContainers int
+	SimulateSeed       int64
 }
 
 func envOrDefault(key, fallback string) string {
@@ -46,6 +85,29 @@ func parseDurationEnv(key string, fallback time.Duration) (time.Duration, error)
 	return value, nil
 }
 
+func parseBoolEnv(key string, fallback bool) (bool, error) {
+	raw, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean for %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// splitList parses a comma separated flag or env value, dropping empty entries.
+func splitList(raw string) []string {
+	var values []string
+	for _, part := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
+
 func Load() (Config, error) {
 	cfg := Config{}
 
@@ -56,6 +118,11 @@ func Load() (Config, error) {
 		pollInterval = duration
 	}
 
+	reconcileInterval, err := parseDurationEnv("AGENT_RECONCILE_INTERVAL", defaultReconcile)
+	if err != nil {
+		return Config{}, err
+	}
+
 	workerLimit := defaultWorkerLimit
 	if raw := envOrDefault("AGENT_MAX_WORKERS", ""); raw != "" {
 		value, err := parseWorkerLimit(raw)
@@ -65,15 +132,130 @@ func Load() (Config, error) {
 		workerLimit = value
 	}
 
+	netPerInterface, err := parseBoolEnv("AGENT_NET_PER_INTERFACE", false)
+	if err != nil {
+		return Config{}, err
+	}
+
+	composeRollups, err := parseBoolEnv("AGENT_COMPOSE_ROLLUPS", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	hostMetrics, err := parseBoolEnv("AGENT_HOST_METRICS", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	flushNew, err := parseBoolEnv("AGENT_FLUSH_NEW_CONTAINERS", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	deltaEpsilon := defaultDeltaEpsilon
+	if raw := envOrDefault("AGENT_DELTA_EPSILON", ""); raw != "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_DELTA_EPSILON: %w", err)
+		}
+		deltaEpsilon = value
+	}
+
+	keyframeInterval, err := parseDurationEnv("AGENT_KEYFRAME_INTERVAL", defaultKeyframe)
+	if err != nil {
+		return Config{}, err
+	}
+
+	replayBuffer := defaultReplayBuffer
+	if raw := envOrDefault("AGENT_REPLAY_BUFFER", ""); raw != "" {
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_REPLAY_BUFFER: %w", err)
+		}
+		replayBuffer = value
+	}
+
+	clientQueue := defaultClientQueue
+	if raw := envOrDefault("AGENT_CLIENT_QUEUE", ""); raw != "" {
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_CLIENT_QUEUE: %w", err)
+		}
+		clientQueue = value
+	}
+
+	compressionLevel := 0
+	if raw := envOrDefault("AGENT_COMPRESSION_LEVEL", ""); raw != "" {
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_COMPRESSION_LEVEL: %w", err)
+		}
+		compressionLevel = value
+	}
+
+	compressionThreshold := defaultCompressThresh
+	if raw := envOrDefault("AGENT_COMPRESSION_THRESHOLD", ""); raw != "" {
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_COMPRESSION_THRESHOLD: %w", err)
+		}
+		compressionThreshold = value
+	}
+
+	simulateContainers := defaultSimContainers
+	if raw := envOrDefault("AGENT_SIMULATE_CONTAINERS", ""); raw != "" {
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_SIMULATE_CONTAINERS: %w", err)
+		}
+		simulateContainers = value
+	}
+
+	var simulateSeed int64
+	if raw := envOrDefault("AGENT_SIMULATE_SEED", ""); raw != "" {
+		value, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for AGENT_SIMULATE_SEED: %w", err)
+		}
+		simulateSeed = value
+	}
+
 	defaults := Config{
-		DockerEndpoint: envOrDefault("AGENT_DOCKER_ENDPOINT", defaultDockerEndpoint),
-		ListenAddr:     envOrDefault("AGENT_LISTEN_ADDR", defaultListenAddr),
-		HostLabel:      envOrDefault("AGENT_HOST_LABEL", defaultHostLabel),
-		PollInterval:   pollInterval,
-		LogLevel:       strings.ToLower(envOrDefault("AGENT_LOG_LEVEL", defaultLogLevel)),
-		WorkerLimit:    workerLimit,
+		DockerEndpoint:    envOrDefault("AGENT_DOCKER_ENDPOINT", defaultDockerEndpoint),
+		ListenAddr:        envOrDefault("AGENT_LISTEN_ADDR", defaultListenAddr),
+		HostLabel:         envOrDefault("AGENT_HOST_LABEL", defaultHostLabel),
+		PollInterval:      pollInterval,
+		LogLevel:          strings.ToLower(envOrDefault("AGENT_LOG_LEVEL", defaultLogLevel)),
+		WorkerLimit:       workerLimit,
+		NetPerInterface:   netPerInterface,
+		StatsMode:         strings.ToLower(envOrDefault("AGENT_STATS_MODE", defaultStatsMode)),
+		ReconcileInterval: reconcileInterval,
+		ComposeRollups:    composeRollups,
+		HostMetrics:       hostMetrics,
+		HostProc:          envOrDefault("AGENT_HOST_PROC", defaultHostProc),
+		HostRoot:          envOrDefault("AGENT_HOST_ROOT", defaultHostRoot),
+		Source:            strings.ToLower(envOrDefault("AGENT_SOURCE", defaultSource)),
+		CgroupRoot:        envOrDefault("AGENT_CGROUP_ROOT", defaultCgroupRoot),
+		FlushNew:          flushNew,
+		DeltaEpsilon:      deltaEpsilon,
+		KeyframeInterval:  keyframeInterval,
+		ReplayBuffer:      replayBuffer,
+		SlowClientPolicy:  strings.ToLower(envOrDefault("AGENT_SLOW_CLIENT_POLICY", defaultSlowPolicy)),
+		ClientQueue:       clientQueue,
+		CompressionLevel:  compressionLevel,
+		CompressThreshold: compressionThreshold,
+		TLSCert:           envOrDefault("AGENT_TLS_CERT", ""),
+		TLSKey:            envOrDefault("AGENT_TLS_KEY", ""),
+		TLSClientCA:       envOrDefault("AGENT_TLS_CLIENT_CA", ""),
+		AuthTokensFile:    envOrDefault("AGENT_AUTH_TOKENS_FILE", ""),
+
+		SimulateContainers: simulateContainers,
+		SimulateSeed:       simulateSeed,
 	}
 
+	labelAllowlist := envOrDefault("AGENT_LABEL_ALLOWLIST", "")
+	groupByLabels := envOrDefault("AGENT_GROUP_BY_LABELS", "")
+
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	flagSet.StringVar(&cfg.DockerEndpoint, "docker-endpoint", defaults.DockerEndpoint, "Docker engine endpoint (unix socket or TCP URL)")
 	flagSet.StringVar(&cfg.ListenAddr, "listen", defaults.ListenAddr, "HTTP listen address for WebSocket server")
@@ -81,31 +263,135 @@ func Load() (Config, error) {
 	flagSet.DurationVar(&cfg.PollInterval, "poll-interval", defaults.PollInterval, "Interval for sampling container stats")
 	flagSet.StringVar(&cfg.LogLevel, "log-level", defaults.LogLevel, "Log level (debug, info, warn, error)")
 	flagSet.IntVar(&cfg.WorkerLimit, "max-workers", defaults.WorkerLimit, "Maximum number of concurrent stats workers")
+	flagSet.BoolVar(&cfg.NetPerInterface, "net-per-interface", defaults.NetPerInterface, "Include per-interface network rates in samples")
+	flagSet.StringVar(&cfg.StatsMode, "stats-mode", defaults.StatsMode, "Stats collection mode (poll, stream)")
+	flagSet.DurationVar(&cfg.ReconcileInterval, "reconcile-interval", defaults.ReconcileInterval, "Interval for full container list reconciliation alongside Docker events")
+	flagSet.BoolVar(&cfg.ComposeRollups, "compose-rollups", defaults.ComposeRollups, "Include per compose project and service totals in batches")
+	flagSet.StringVar(&groupByLabels, "group-by-labels", groupByLabels, "Comma separated label keys to aggregate container totals by")
+	flagSet.BoolVar(&cfg.HostMetrics, "host-metrics", defaults.HostMetrics, "Include host CPU, memory, load and disk metrics in batches")
+	flagSet.StringVar(&cfg.HostProc, "host-proc", defaults.HostProc, "Path to the host's /proc (e.g. /host/proc when running in a container)")
+	flagSet.StringVar(&cfg.HostRoot, "host-root", defaults.HostRoot, "Path where the host's root filesystem is mounted, used for disk usage")
+	flagSet.StringVar(&cfg.Source, "source", defaults.Source, "Stats source (docker, cgroup, simulate)")
+	flagSet.StringVar(&cfg.CgroupRoot, "cgroup-root", defaults.CgroupRoot, "Path to the cgroup v2 hierarchy read by the cgroup source")
+	flagSet.BoolVar(&cfg.FlushNew, "flush-new-containers", defaults.FlushNew, "Publish a batch as soon as a new container reports its first sample")
+	flagSet.Float64Var(&cfg.DeltaEpsilon, "delta-epsilon", defaults.DeltaEpsilon, "Relative change below which a container is left out of delta batches")
+	flagSet.DurationVar(&cfg.KeyframeInterval, "keyframe-interval", defaults.KeyframeInterval, "Interval between full batches sent to clients receiving deltas")
+	flagSet.IntVar(&cfg.ReplayBuffer, "replay-buffer", defaults.ReplayBuffer, "Number of recent batches kept for clients resuming with ?since (0 disables)")
+	flagSet.StringVar(&cfg.SlowClientPolicy, "slow-client-policy", defaults.SlowClientPolicy, "What to do when a client falls behind (coalesce, disconnect)")
+	flagSet.IntVar(&cfg.ClientQueue, "client-queue", defaults.ClientQueue, "Messages that may wait for one client before it is disconnected")
+	flagSet.IntVar(&cfg.CompressionLevel, "compression-level", defaults.CompressionLevel, "permessage-deflate level for WebSocket clients that support it (0 disables, 1 fastest to 9 smallest)")
+	flagSet.IntVar(&cfg.CompressThreshold, "compression-threshold", defaults.CompressThreshold, "Messages smaller than this many bytes are sent uncompressed")
+	flagSet.StringVar(&cfg.TLSCert, "tls-cert", defaults.TLSCert, "PEM certificate file; serves HTTPS and wss:// when set together with --tls-key")
+	flagSet.StringVar(&cfg.TLSKey, "tls-key", defaults.TLSKey, "PEM private key file for --tls-cert")
+	flagSet.StringVar(&cfg.TLSClientCA, "tls-client-ca", defaults.TLSClientCA, "PEM CA bundle; when set, clients must present a certificate signed by it")
+	flagSet.StringVar(&cfg.AuthTokensFile, "auth-tokens-file", defaults.AuthTokensFile, "File of name:token lines; when set, /ws, /events and /metrics require one of the tokens")
+	flagSet.IntVar(&cfg.SimulateContainers, "simulate-containers", defaults.SimulateContainers, "Number of containers invented by the simulate source")
+	flagSet.Int64Var(&cfg.SimulateSeed, "simulate-seed", defaults.SimulateSeed, "Random seed for the simulate source (0 picks one)")
+	flagSet.StringVar(&labelAllowlist, "label-allowlist", labelAllowlist, "Comma separated container label keys to include in samples (trailing * matches a prefix)")
 
 	if err := flagSet.Parse(filterArgs(os.Args[1:])); err != nil {
 		return Config{}, err
 	}
 
 	cfg.LogLevel = strings.ToLower(strings.TrimSpace(cfg.LogLevel))
+	cfg.StatsMode = strings.ToLower(strings.TrimSpace(cfg.StatsMode))
+	cfg.Source = strings.ToLower(strings.TrimSpace(cfg.Source))
+	cfg.SlowClientPolicy = strings.ToLower(strings.TrimSpace(cfg.SlowClientPolicy))
+	cfg.LabelAllowlist = splitList(labelAllowlist)
+	cfg.GroupByLabels = splitList(groupByLabels)
 
 	if cfg.PollInterval <= 0 {
 		return Config{}, fmt.Errorf("poll interval must be positive")
 	}
+	if cfg.ReconcileInterval <= 0 {
+		return Config{}, fmt.Errorf("reconcile interval must be positive")
+	}
 	if cfg.WorkerLimit <= 0 {
 		cfg.WorkerLimit = 1
 	}
+	if cfg.DeltaEpsilon < 0 || cfg.DeltaEpsilon >= 1 {
+		return Config{}, fmt.Errorf("delta epsilon must be between 0 and 1")
+	}
+	if cfg.KeyframeInterval <= 0 {
+		return Config{}, fmt.Errorf("keyframe interval must be positive")
+	}
+	if cfg.ReplayBuffer < 0 {
+		return Config{}, fmt.Errorf("replay buffer must not be negative")
+	}
+	if cfg.ClientQueue <= 0 {
+		return Config{}, fmt.Errorf("client queue must be positive")
+	}
+	if cfg.CompressionLevel < 0 || cfg.CompressionLevel > 9 {
+		return Config{}, fmt.Errorf("compression level must be between 0 and 9")
+	}
+	if cfg.CompressThreshold < 0 {
+		return Config{}, fmt.Errorf("compression threshold must not be negative")
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return Config{}, fmt.Errorf("tls cert and tls key must be set together")
+	}
+	if cfg.TLSClientCA != "" && cfg.TLSCert == "" {
+		return Config{}, fmt.Errorf("tls client ca requires tls cert and tls key")
+	}
+	if cfg.SlowClientPolicy != "coalesce" && cfg.SlowClientPolicy != "disconnect" {
+		return Config{}, fmt.Errorf("invalid slow client policy %q: must be coalesce or disconnect", cfg.SlowClientPolicy)
+	}
+	if cfg.SimulateContainers <= 0 {
+		return Config{}, fmt.Errorf("simulate containers must be positive")
+	}
+	if cfg.StatsMode != "poll" && cfg.StatsMode != "stream" {
+		return Config{}, fmt.Errorf("invalid stats mode %q: must be poll or stream", cfg.StatsMode)
+	}
+	if cfg.Source != "docker" && cfg.Source != "cgroup" && cfg.Source != "simulate" {
+		return Config{}, fmt.Errorf("invalid source %q: must be docker, cgroup or simulate", cfg.Source)
+	}
+	if cfg.Source != "docker" && cfg.StatsMode == "stream" {
+		return Config{}, fmt.Errorf("stats mode stream is only supported by the docker source")
+	}
 
 	return cfg, nil
 }
 
 func filterArgs(args []string) []string {
 	allowed := map[string]bool{
-		"--docker-endpoint": true,
-		"--listen":          true,
-		"--host-label":      true,
-		"--poll-interval":   true,
-		"--log-level":       true,
-		"--max-workers":     true,
+		"--docker-endpoint":       true,
+		"--listen":                true,
+		"--host-label":            true,
+		"--poll-interval":         true,
+		"--log-level":             true,
+		"--max-workers":           true,
+		"--net-per-interface":     true,
+		"--stats-mode":            true,
+		"--reconcile-interval":    true,
+		"--label-allowlist":       true,
+		"--compose-rollups":       true,
+		"--group-by-labels":       true,
+		"--host-metrics":          true,
+		"--host-proc":             true,
+		"--host-root":             true,
+		"--source":                true,
+		"--cgroup-root":           true,
+		"--simulate-containers":   true,
+		"--simulate-seed":         true,
+		"--flush-new-containers":  true,
+		"--delta-epsilon":         true,
+		"--keyframe-interval":     true,
+		"--replay-buffer":         true,
+		"--slow-client-policy":    true,
+		"--client-queue":          true,
+		"--compression-level":     true,
+		"--compression-threshold": true,
+		"--tls-cert":              true,
+		"--tls-key":               true,
+		"--tls-client-ca":         true,
+		"--auth-tokens-file":      true,
+	}
+	// Boolean flags never consume the following argument as their value.
+	boolFlags := map[string]bool{
+		"--net-per-interface":    true,
+		"--compose-rollups":      true,
+		"--host-metrics":         true,
+		"--flush-new-containers": true,
 	}
 
 	var filtered []string
@@ -122,7 +408,7 @@ func filterArgs(args []string) []string {
 			parts := strings.SplitN(arg, "=", 2)
 			if allowed[parts[0]] {
 				filtered = append(filtered, arg)
-				if len(parts) == 1 {
+				if len(parts) == 1 && !boolFlags[parts[0]] {
 					if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
 						filtered = append(filtered, args[i+1])
 						skipNext = true
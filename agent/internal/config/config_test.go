@@ -54,3 +54,270 @@ func TestLoadInvalidWorkerLimit(t *testing.T) {
 		t.Fatalf("expected error for invalid worker limit")
 	}
 }
+
+func TestLoadNetPerInterfaceEnv(t *testing.T) {
+	t.Setenv("AGENT_NET_PER_INTERFACE", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.NetPerInterface {
+		t.Fatalf("expected per-interface network stats to be enabled")
+	}
+}
+
+func TestFilterArgsBoolFlag(t *testing.T) {
+	got := filterArgs([]string{"--net-per-interface", "extra", "--listen", ":9000"})
+	want := []string{"--net-per-interface", "--listen", ":9000"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected args: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected args: %v", got)
+		}
+	}
+}
+
+func TestLoadStatsMode(t *testing.T) {
+	t.Setenv("AGENT_STATS_MODE", "Stream")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.StatsMode != "stream" {
+		t.Fatalf("unexpected stats mode: %s", cfg.StatsMode)
+	}
+}
+
+func TestLoadInvalidStatsMode(t *testing.T) {
+	t.Setenv("AGENT_STATS_MODE", "push")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for invalid stats mode")
+	}
+}
+
+func TestLoadReconcileInterval(t *testing.T) {
+	t.Setenv("AGENT_RECONCILE_INTERVAL", "1m")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.ReconcileInterval != time.Minute {
+		t.Fatalf("unexpected reconcile interval: %s", cfg.ReconcileInterval)
+	}
+
+	t.Setenv("AGENT_RECONCILE_INTERVAL", "0s")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for non-positive reconcile interval")
+	}
+}
+
+func TestLoadLabelAllowlist(t *testing.T) {
+	t.Setenv("AGENT_LABEL_ALLOWLIST", "com.docker.compose.project, team ,,org.opencontainers.*")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	want := []string{"com.docker.compose.project", "team", "org.opencontainers.*"}
+	if len(cfg.LabelAllowlist) != len(want) {
+		t.Fatalf("unexpected label allowlist: %v", cfg.LabelAllowlist)
+	}
+	for i := range want {
+		if cfg.LabelAllowlist[i] != want[i] {
+			t.Fatalf("unexpected label allowlist: %v", cfg.LabelAllowlist)
+		}
+	}
+}
+
+func TestLoadHostPaths(t *testing.T) {
+	t.Setenv("AGENT_HOST_PROC", "/host/proc")
+	t.Setenv("AGENT_HOST_ROOT", "/host")
+	t.Setenv("AGENT_HOST_METRICS", "false")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.HostProc != "/host/proc" || cfg.HostRoot != "/host" {
+		t.Fatalf("unexpected host paths: %s %s", cfg.HostProc, cfg.HostRoot)
+	}
+	if cfg.HostMetrics {
+		t.Fatalf("expected host metrics to be disabled")
+	}
+}
+
+func TestLoadCgroupSource(t *testing.T) {
+	t.Setenv("AGENT_SOURCE", "CGROUP")
+	t.Setenv("AGENT_CGROUP_ROOT", "/host/sys/fs/cgroup")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Source != "cgroup" || cfg.CgroupRoot != "/host/sys/fs/cgroup" {
+		t.Fatalf("unexpected source config: %s %s", cfg.Source, cfg.CgroupRoot)
+	}
+
+	t.Setenv("AGENT_STATS_MODE", "stream")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for stream mode with cgroup source")
+	}
+}
+
+func TestLoadSimulateSource(t *testing.T) {
+	t.Setenv("AGENT_SOURCE", "simulate")
+	t.Setenv("AGENT_SIMULATE_CONTAINERS", "40")
+	t.Setenv("AGENT_SIMULATE_SEED", "7")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Source != "simulate" || cfg.SimulateContainers != 40 || cfg.SimulateSeed != 7 {
+		t.Fatalf("unexpected simulate config: %+v", cfg)
+	}
+
+	t.Setenv("AGENT_SIMULATE_CONTAINERS", "0")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for zero simulated containers")
+	}
+}
+
+func TestLoadFlushNewContainers(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.FlushNew {
+		t.Fatalf("expected new container flush to default on")
+	}
+
+	t.Setenv("AGENT_FLUSH_NEW_CONTAINERS", "false")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.FlushNew {
+		t.Fatalf("expected new container flush to be disabled")
+	}
+}
+
+func TestLoadDeltaSettings(t *testing.T) {
+	t.Setenv("AGENT_DELTA_EPSILON", "0.05")
+	t.Setenv("AGENT_KEYFRAME_INTERVAL", "1m")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.DeltaEpsilon != 0.05 || cfg.KeyframeInterval != time.Minute {
+		t.Fatalf("unexpected delta settings: %f %s", cfg.DeltaEpsilon, cfg.KeyframeInterval)
+	}
+
+	t.Setenv("AGENT_DELTA_EPSILON", "2")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for epsilon outside [0, 1)")
+	}
+}
+
+func TestLoadReplayBuffer(t *testing.T) {
+	t.Setenv("AGENT_REPLAY_BUFFER", "0")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.ReplayBuffer != 0 {
+		t.Fatalf("unexpected replay buffer: %d", cfg.ReplayBuffer)
+	}
+
+	t.Setenv("AGENT_REPLAY_BUFFER", "-1")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for negative replay buffer")
+	}
+}
+
+func TestLoadSlowClientPolicy(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.SlowClientPolicy != "coalesce" || cfg.ClientQueue != 64 {
+		t.Fatalf("unexpected defaults: %s %d", cfg.SlowClientPolicy, cfg.ClientQueue)
+	}
+
+	t.Setenv("AGENT_SLOW_CLIENT_POLICY", "Disconnect")
+	t.Setenv("AGENT_CLIENT_QUEUE", "16")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.SlowClientPolicy != "disconnect" || cfg.ClientQueue != 16 {
+		t.Fatalf("unexpected policy: %s %d", cfg.SlowClientPolicy, cfg.ClientQueue)
+	}
+
+	t.Setenv("AGENT_SLOW_CLIENT_POLICY", "block")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
+
+func TestLoadCompression(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.CompressionLevel != 0 || cfg.CompressThreshold != 1024 {
+		t.Fatalf("unexpected defaults: %d %d", cfg.CompressionLevel, cfg.CompressThreshold)
+	}
+
+	t.Setenv("AGENT_COMPRESSION_LEVEL", "6")
+	t.Setenv("AGENT_COMPRESSION_THRESHOLD", "256")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.CompressionLevel != 6 || cfg.CompressThreshold != 256 {
+		t.Fatalf("unexpected compression: %d %d", cfg.CompressionLevel, cfg.CompressThreshold)
+	}
+
+	t.Setenv("AGENT_COMPRESSION_LEVEL", "10")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for compression level above 9")
+	}
+}
+
+func TestLoadTLS(t *testing.T) {
+	t.Setenv("AGENT_TLS_CERT", "/certs/agent.pem")
+	t.Setenv("AGENT_TLS_KEY", "/certs/agent-key.pem")
+	t.Setenv("AGENT_TLS_CLIENT_CA", "/certs/ca.pem")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.TLSCert != "/certs/agent.pem" || cfg.TLSKey != "/certs/agent-key.pem" || cfg.TLSClientCA != "/certs/ca.pem" {
+		t.Fatalf("unexpected tls settings: %+v", cfg)
+	}
+
+	t.Setenv("AGENT_TLS_KEY", "")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for certificate without key")
+	}
+
+	t.Setenv("AGENT_TLS_CERT", "")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for client CA without certificate")
+	}
+}
+
+func TestLoadAuthTokensFile(t *testing.T) {
+	t.Setenv("AGENT_AUTH_TOKENS_FILE", "/etc/agent/tokens")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.AuthTokensFile != "/etc/agent/tokens" {
+		t.Fatalf("unexpected tokens file: %q", cfg.AuthTokensFile)
+	}
+}
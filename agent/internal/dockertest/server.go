@@ -0,0 +1,249 @@
+// Package dockertest serves the subset of the Docker Engine API used by the agent,
+// backed by a scriptable stats.FakeSource, so the real Docker client can be
+// exercised in tests without a daemon.
+package dockertest
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/stats"
+)
+
+// DefaultAPIVersion is advertised on /_ping unless Server.APIVersion is changed.
+const DefaultAPIVersion = "1.45"
+
+var versionPrefix = regexp.MustCompile(`^/v(\d+\.\d+)(/.*)$`)
+
+// Server is a fake Docker daemon. Containers, stats and events are scripted through
+// the embedded FakeSource; every change is visible to the next request.
+type Server struct {
+	*stats.FakeSource
+
+	// APIVersion is returned from /_ping, which the client uses for version
+	// negotiation. Set it before the client is created.
+	APIVersion string
+	// StreamInterval is the delay between frames of a streaming stats request.
+	StreamInterval time.Duration
+
+	server *httptest.Server
+	host   string
+
+	mu       sync.Mutex
+	versions map[string]int
+}
+
+// New starts a fake daemon on a loopback TCP port and stops it when the test ends.
+func New(t testing.TB) *Server {
+	t.Helper()
+	s := newServer()
+	s.server = httptest.NewServer(s.handler())
+	s.host = "tcp://" + s.server.Listener.Addr().String()
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// NewUnix starts a fake daemon on a unix socket in a temporary directory.
+func NewUnix(t testing.TB) *Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", path, err)
+	}
+	s := newServer()
+	s.server = httptest.NewUnstartedServer(s.handler())
+	s.server.Listener = listener
+	s.server.Start()
+	s.host = "unix://" + path
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		FakeSource:     stats.NewFakeSource(),
+		APIVersion:     DefaultAPIVersion,
+		StreamInterval: time.Second,
+		versions:       make(map[string]int),
+	}
+}
+
+// Host is the endpoint to pass to client.WithHost or the agent's --docker-endpoint.
+func (s *Server) Host() string {
+	return s.host
+}
+
+// Requests reports how many API requests were made with each version prefix.
+func (s *Server) Requests() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int, len(s.versions))
+	for version, count := range s.versions {
+		counts[version] = count
+	}
+	return counts
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_ping", s.ping)
+	mux.HandleFunc("HEAD /_ping", s.ping)
+	mux.HandleFunc("GET /version", s.version)
+	mux.HandleFunc("GET /containers/json", s.listContainers)
+	mux.HandleFunc("GET /containers/{id}/json", s.inspectContainer)
+	mux.HandleFunc("GET /containers/{id}/stats", s.containerStats)
+	mux.HandleFunc("GET /events", s.events)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if match := versionPrefix.FindStringSubmatch(r.URL.Path); match != nil {
+			s.mu.Lock()
+			s.versions[match[1]]++
+			s.mu.Unlock()
+			r.URL.Path = match[2]
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) ping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("API-Version", s.APIVersion)
+	w.Header().Set("OSType", "linux")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+func (s *Server) version(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"Version":       "27.3.1-fake",
+		"ApiVersion":    s.APIVersion,
+		"MinAPIVersion": "1.24",
+		"Os":            "linux",
+	})
+}
+
+func (s *Server) listContainers(w http.ResponseWriter, r *http.Request) {
+	args, err := filters.FromJSON(r.URL.Query().Get("filters"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ids := args.Get("id")
+	if len(ids) > 1 {
+		writeError(w, http.StatusBadRequest, errors.New("fake daemon supports a single id filter"))
+		return
+	}
+	id := ""
+	if len(ids) == 1 {
+		id = ids[0]
+	}
+
+	containers, err := s.ListContainers(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, containers)
+}
+
+func (s *Server) inspectContainer(w http.ResponseWriter, r *http.Request) {
+	info, err := s.InspectContainer(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// containerStats answers one-shot requests with the current reading and streams
+// the reading every StreamInterval otherwise, until the container disappears.
+func (s *Server) containerStats(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	reading, err := s.ContainerStats(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if stream := r.URL.Query().Get("stream"); stream == "0" || stream == "false" {
+		_ = json.NewEncoder(w).Encode(reading)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	ticker := time.NewTicker(s.StreamInterval)
+	defer ticker.Stop()
+	for {
+		if err := encoder.Encode(reading); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		if reading, err = s.ContainerStats(r.Context(), id); err != nil {
+			return
+		}
+	}
+}
+
+// events streams whatever is emitted on the FakeSource. Filters are not applied;
+// tests only emit what they want the client to see.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	msgs, errs := s.Events(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case msg := <-msgs:
+			if err := encoder.Encode(msg); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-errs:
+			// Ending the response is how the daemon reports a broken stream.
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"message": strings.TrimSpace(err.Error())})
+}
@@ -0,0 +1,259 @@
+package host
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// Reader samples host-wide metrics from procfs and statfs. When the agent runs in
+// a container, procRoot points at the host's /proc (e.g. /host/proc) and rootPath
+// at a bind mount of the host's / so disk usage reflects the host filesystems.
+type Reader struct {
+	procRoot string
+	rootPath string
+
+	mu      sync.Mutex
+	prevCPU cpuTimes
+}
+
+type cpuTimes struct {
+	busy  uint64
+	total uint64
+}
+
+func NewReader(procRoot, rootPath string) *Reader {
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	if rootPath == "" {
+		rootPath = "/"
+	}
+	return &Reader{procRoot: procRoot, rootPath: rootPath}
+}
+
+// Read returns a fresh snapshot. CPU usage is measured between consecutive calls,
+// so the first call reports 0. Disk usage failures are skipped per mount; errors
+// reading the core proc files are returned.
+func (r *Reader) Read() (types.HostMetrics, error) {
+	var metrics types.HostMetrics
+
+	times, cpus, err := r.readCPU()
+	if err != nil {
+		return types.HostMetrics{}, err
+	}
+	metrics.CPUs = cpus
+
+	r.mu.Lock()
+	if r.prevCPU.total > 0 && times.total > r.prevCPU.total && times.busy >= r.prevCPU.busy {
+		metrics.CPUPct = float64(times.busy-r.prevCPU.busy) / float64(times.total-r.prevCPU.total) * 100
+	}
+	r.prevCPU = times
+	r.mu.Unlock()
+
+	if err := r.readMemory(&metrics); err != nil {
+		return types.HostMetrics{}, err
+	}
+	if err := r.readLoad(&metrics); err != nil {
+		return types.HostMetrics{}, err
+	}
+	if err := r.readUptime(&metrics); err != nil {
+		return types.HostMetrics{}, err
+	}
+	metrics.Disks = r.readDisks()
+
+	return metrics, nil
+}
+
+func (r *Reader) readCPU() (cpuTimes, int, error) {
+	file, err := os.Open(filepath.Join(r.procRoot, "stat"))
+	if err != nil {
+		return cpuTimes{}, 0, err
+	}
+	defer file.Close()
+
+	var times cpuTimes
+	found := false
+	cpus := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cpus++
+			continue
+		}
+		// user nice system idle iowait irq softirq steal; guest time is already
+		// included in user/nice.
+		for i, raw := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				return cpuTimes{}, 0, fmt.Errorf("parse %s: %w", filepath.Join(r.procRoot, "stat"), err)
+			}
+			times.total += value
+			if i != 3 && i != 4 {
+				times.busy += value
+			}
+		}
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuTimes{}, 0, err
+	}
+	if !found {
+		return cpuTimes{}, 0, errors.New("no aggregate cpu line in stat")
+	}
+	return times, cpus, nil
+}
+
+func (r *Reader) readMemory(metrics *types.HostMetrics) error {
+	file, err := os.Open(filepath.Join(r.procRoot, "meminfo"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	metrics.MemTotalBytes = values["MemTotal"]
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14 lack MemAvailable; approximate it.
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	metrics.MemAvailableBytes = min(available, metrics.MemTotalBytes)
+	metrics.MemUsedBytes = metrics.MemTotalBytes - metrics.MemAvailableBytes
+	metrics.SwapTotalBytes = values["SwapTotal"]
+	if free := values["SwapFree"]; free <= metrics.SwapTotalBytes {
+		metrics.SwapUsedBytes = metrics.SwapTotalBytes - free
+	}
+	return nil
+}
+
+func (r *Reader) readLoad(metrics *types.HostMetrics) error {
+	data, err := os.ReadFile(filepath.Join(r.procRoot, "loadavg"))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return fmt.Errorf("unexpected loadavg format %q", strings.TrimSpace(string(data)))
+	}
+	loads := make([]float64, 3)
+	for i := range loads {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return fmt.Errorf("parse loadavg: %w", err)
+		}
+		loads[i] = value
+	}
+	metrics.Load1, metrics.Load5, metrics.Load15 = loads[0], loads[1], loads[2]
+	return nil
+}
+
+func (r *Reader) readUptime(metrics *types.HostMetrics) error {
+	data, err := os.ReadFile(filepath.Join(r.procRoot, "uptime"))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return errors.New("empty uptime")
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return fmt.Errorf("parse uptime: %w", err)
+	}
+	metrics.UptimeSecs = uint64(uptime)
+	return nil
+}
+
+// readDisks reports usage for block-device backed mounts of the host's init mount
+// namespace. <proc>/mounts resolves to the reading process's own namespace, which
+// inside a container is the container's, so PID 1's view is preferred.
+func (r *Reader) readDisks() []types.DiskUsage {
+	data, err := os.ReadFile(filepath.Join(r.procRoot, "1", "mounts"))
+	if err != nil {
+		data, err = os.ReadFile(filepath.Join(r.procRoot, "mounts"))
+		if err != nil {
+			return nil
+		}
+	}
+
+	var disks []types.DiskUsage
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		device, mount, fsType := fields[0], unescapeMount(fields[1]), fields[2]
+		if !strings.HasPrefix(device, "/dev/") || seen[device] {
+			continue
+		}
+		seen[device] = true
+
+		usage, err := statfs(filepath.Join(r.rootPath, mount))
+		if err != nil {
+			continue
+		}
+		usage.Mount = mount
+		usage.Device = device
+		usage.FSType = fsType
+		disks = append(disks, usage)
+	}
+	return disks
+}
+
+// unescapeMount decodes the octal escapes (\040 for space etc.) used in mounts.
+func unescapeMount(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProcFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func fakeProc(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeProcFile(t, root, "stat", "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 50 0 50 350 50 0 0 0 0 0\ncpu1 50 0 50 350 50 0 0 0 0 0\nintr 1 2 3\n")
+	writeProcFile(t, root, "meminfo", "MemTotal:        8000 kB\nMemFree:         1000 kB\nMemAvailable:    6000 kB\nSwapTotal:       2000 kB\nSwapFree:        1500 kB\n")
+	writeProcFile(t, root, "loadavg", "0.50 0.25 0.10 2/300 1234\n")
+	writeProcFile(t, root, "uptime", "3600.42 7000.00\n")
+	writeProcFile(t, root, "1/mounts", "proc /proc proc rw 0 0\noverlay / overlay rw 0 0\n")
+	return root
+}
+
+func TestReaderRead(t *testing.T) {
+	root := fakeProc(t)
+	reader := NewReader(root, "/")
+
+	metrics, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+
+	if metrics.CPUs != 2 {
+		t.Fatalf("unexpected cpu count: %d", metrics.CPUs)
+	}
+	if metrics.CPUPct != 0 {
+		t.Fatalf("expected zero cpu on first read, got %f", metrics.CPUPct)
+	}
+	if metrics.MemTotalBytes != 8000*1024 || metrics.MemAvailableBytes != 6000*1024 || metrics.MemUsedBytes != 2000*1024 {
+		t.Fatalf("unexpected memory: %+v", metrics)
+	}
+	if metrics.SwapTotalBytes != 2000*1024 || metrics.SwapUsedBytes != 500*1024 {
+		t.Fatalf("unexpected swap: %+v", metrics)
+	}
+	if metrics.Load1 != 0.5 || metrics.Load5 != 0.25 || metrics.Load15 != 0.1 {
+		t.Fatalf("unexpected load: %+v", metrics)
+	}
+	if metrics.UptimeSecs != 3600 {
+		t.Fatalf("unexpected uptime: %d", metrics.UptimeSecs)
+	}
+	if len(metrics.Disks) != 0 {
+		t.Fatalf("expected pseudo filesystems to be skipped, got %+v", metrics.Disks)
+	}
+
+	// 200 more busy jiffies out of 400 total since the previous read.
+	writeProcFile(t, root, "stat", "cpu  200 0 200 850 150 0 0 0 0 0\n")
+	metrics, err = reader.Read()
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if metrics.CPUPct != 50 {
+		t.Fatalf("expected 50%% cpu, got %f", metrics.CPUPct)
+	}
+}
+
+func TestReaderMissingProc(t *testing.T) {
+	if _, err := NewReader(t.TempDir(), "/").Read(); err == nil {
+		t.Fatalf("expected error for empty proc root")
+	}
+}
+
+func TestUnescapeMount(t *testing.T) {
+	if got := unescapeMount(`/mnt/my\040disk`); got != "/mnt/my disk" {
+		t.Fatalf("unexpected unescaped mount: %q", got)
+	}
+}
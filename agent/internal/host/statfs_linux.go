@@ -0,0 +1,24 @@
+//go:build linux
+
+package host
+
+import (
+	"syscall"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func statfs(path string) (types.DiskUsage, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return types.DiskUsage{}, err
+	}
+	blockSize := uint64(fs.Bsize)
+	total := uint64(fs.Blocks) * blockSize
+	free := uint64(fs.Bfree) * blockSize
+	return types.DiskUsage{
+		TotalBytes:     total,
+		UsedBytes:      total - free,
+		AvailableBytes: uint64(fs.Bavail) * blockSize,
+	}, nil
+}
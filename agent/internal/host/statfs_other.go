@@ -0,0 +1,13 @@
+//go:build !linux
+
+package host
+
+import (
+	"errors"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func statfs(string) (types.DiskUsage, error) {
+	return types.DiskUsage{}, errors.New("disk usage is only supported on linux")
+}
@@ -0,0 +1,437 @@
+// Package simulate invents containers with plausible resource curves and feeds them
+// through a stats.FakeSource, so the agent and dashboard can be demoed without
+// Docker.
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/stats"
+)
+
+const (
+	defaultContainers = 12
+	simulatedCPUs     = 8
+	packetSize        = 900
+
+	// Mean time between random occurrences, per container.
+	spikeEvery   = time.Minute
+	restartEvery = 5 * time.Minute
+	churnEvery   = 10 * time.Minute
+	healthEvery  = 2 * time.Minute
+)
+
+type Options struct {
+	// Containers is how many containers are kept running.
+	Containers int
+	// Interval is the time between stats updates; match it to the poll interval.
+	Interval time.Duration
+	// Seed makes runs reproducible; 0 picks a random seed.
+	Seed int64
+}
+
+// profile describes a kind of workload. CPU figures are in cores, rates in bytes
+// per second.
+type profile struct {
+	project   string
+	service   string
+	image     string
+	port      uint16
+	cpuBase   float64
+	cpuSwing  float64
+	period    time.Duration
+	memBase   uint64
+	memSwing  uint64
+	memLimit  uint64
+	leakRate  uint64
+	netRx     float64
+	netTx     float64
+	diskRead  float64
+	diskWrite float64
+	pids      uint64
+	health    bool
+}
+
+const mib = 1 << 20
+
+var profiles = []profile{
+	{project: "shop", service: "web", image: "nginx:1.27", port: 80, cpuBase: 0.05, cpuSwing: 0.3, period: 90 * time.Second, memBase: 24 * mib, memSwing: 8 * mib, memLimit: 256 * mib, netRx: 180e3, netTx: 1.2e6, pids: 5, health: true},
+	{project: "shop", service: "api", image: "node:22-alpine", port: 3000, cpuBase: 0.2, cpuSwing: 0.8, period: 2 * time.Minute, memBase: 180 * mib, memSwing: 60 * mib, memLimit: 512 * mib, netRx: 400e3, netTx: 650e3, pids: 11, health: true},
+	{project: "shop", service: "worker", image: "python:3.12-slim", cpuBase: 0.4, cpuSwing: 1.2, period: 45 * time.Second, memBase: 220 * mib, memSwing: 40 * mib, memLimit: 768 * mib, leakRate: 512 << 10, netRx: 40e3, netTx: 20e3, diskWrite: 200e3, pids: 4},
+	{project: "shop", service: "db", image: "postgres:16", port: 5432, cpuBase: 0.15, cpuSwing: 0.5, period: 5 * time.Minute, memBase: 420 * mib, memSwing: 30 * mib, memLimit: 2048 * mib, netRx: 250e3, netTx: 300e3, diskRead: 800e3, diskWrite: 1.5e6, pids: 18, health: true},
+	{project: "shop", service: "cache", image: "redis:7", port: 6379, cpuBase: 0.03, cpuSwing: 0.1, period: time.Minute, memBase: 64 * mib, memSwing: 16 * mib, memLimit: 256 * mib, netRx: 120e3, netTx: 140e3, pids: 6},
+	{project: "analytics", service: "ingest", image: "golang:1.25", port: 8080, cpuBase: 0.3, cpuSwing: 0.6, period: 30 * time.Second, memBase: 90 * mib, memSwing: 30 * mib, memLimit: 512 * mib, netRx: 2e6, netTx: 150e3, pids: 14, health: true},
+	{project: "analytics", service: "queue", image: "rabbitmq:3.13", port: 5672, cpuBase: 0.1, cpuSwing: 0.2, period: 3 * time.Minute, memBase: 140 * mib, memSwing: 20 * mib, memLimit: 1024 * mib, netRx: 900e3, netTx: 880e3, diskWrite: 300e3, pids: 30},
+	{project: "infra", service: "prometheus", image: "prom/prometheus:v2.54", port: 9090, cpuBase: 0.08, cpuSwing: 0.25, period: 15 * time.Second, memBase: 300 * mib, memSwing: 80 * mib, memLimit: 1024 * mib, leakRate: 128 << 10, netRx: 350e3, netTx: 60e3, diskWrite: 400e3, pids: 12},
+	{project: "infra", service: "grafana", image: "grafana/grafana:11.2.0", port: 3001, cpuBase: 0.02, cpuSwing: 0.1, period: 4 * time.Minute, memBase: 110 * mib, memSwing: 10 * mib, memLimit: 512 * mib, netRx: 30e3, netTx: 90e3, pids: 15, health: true},
+}
+
+// crashExitCodes are typical for an application error and for SIGKILL.
+var crashExitCodes = []int{1, 137}
+
+type simContainer struct {
+	id        string
+	name      string
+	profile   profile
+	phase     float64
+	created   time.Time
+	startedAt time.Time
+	restarts  int
+	health    string
+	port      uint16
+
+	spikeLeft time.Duration
+	spikeCPU  float64
+	leaked    uint64
+
+	cpuTotal              uint64
+	prevCPU               container.CPUStats
+	prevRead              time.Time
+	rxBytes, txBytes      uint64
+	rxPackets, txPackets  uint64
+	readBytes, writeBytes uint64
+	readOps, writeOps     uint64
+}
+
+// Simulator owns the fake containers. It is driven from a single goroutine.
+type Simulator struct {
+	source   *stats.FakeSource
+	rng      *rand.Rand
+	interval time.Duration
+
+	containers []*simContainer
+	replicas   map[string]int
+	ports      map[uint16]bool
+	system     uint64
+	last       time.Time
+}
+
+// New creates the initial containers on source. They are registered without
+// events so the collector's startup listing finds them.
+func New(source *stats.FakeSource, opts Options) *Simulator {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	target := opts.Containers
+	if target <= 0 {
+		target = defaultContainers
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	s := &Simulator{
+		source:   source,
+		rng:      rand.New(rand.NewSource(seed)),
+		interval: interval,
+		replicas: make(map[string]int),
+		ports:    make(map[uint16]bool),
+	}
+
+	now := time.Now()
+	s.last = now
+	for i := 0; i < target; i++ {
+		cont := s.newContainer(profiles[i%len(profiles)], now.Add(-time.Duration(s.rng.Intn(72))*time.Hour))
+		s.source.SetContainer(s.listing(cont))
+		s.source.SetInspect(cont.id, s.inspect(cont))
+		s.source.SetStats(cont.id, s.reading(cont, now))
+	}
+	return s
+}
+
+// Run advances the simulation every interval until ctx is done.
+func (s *Simulator) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.step(now)
+		}
+	}
+}
+
+func (s *Simulator) step(now time.Time) {
+	elapsed := now.Sub(s.last)
+	if elapsed <= 0 {
+		return
+	}
+	s.last = now
+	s.system += uint64(elapsed) * simulatedCPUs
+
+	// Iterate over a copy: churn replaces entries. Restarted and replaced containers
+	// already published their fresh zeroed reading.
+	for _, cont := range append([]*simContainer(nil), s.containers...) {
+		switch {
+		case s.chance(elapsed, churnEvery):
+			s.replace(cont, now)
+			continue
+		case s.chance(elapsed, restartEvery):
+			s.restart(cont, now, crashExitCodes[s.rng.Intn(len(crashExitCodes))])
+			continue
+		case cont.profile.health && s.chance(elapsed, healthEvery):
+			s.flipHealth(cont)
+		}
+
+		s.advance(cont, now, elapsed)
+		if anon, cache := s.memory(cont, now); anon+cache >= cont.profile.memLimit*95/100 {
+			// The leak ran into the limit: the kernel kills it and Docker restarts it.
+			s.source.Emit(stats.ContainerEvent(events.ActionOOM, cont.id, cont.name, nil))
+			s.restart(cont, now, 137)
+			continue
+		}
+		s.source.SetStats(cont.id, s.reading(cont, now))
+	}
+}
+
+// advance accumulates counters over elapsed using the profile's curves.
+func (s *Simulator) advance(cont *simContainer, now time.Time, elapsed time.Duration) {
+	p := cont.profile
+	wave := s.wave(cont, now)
+
+	if cont.spikeLeft <= 0 && s.chance(elapsed, spikeEvery) {
+		cont.spikeLeft = time.Duration(5+s.rng.Intn(15)) * time.Second
+		cont.spikeCPU = 0.5 + s.rng.Float64()*2.5
+	}
+	cores := p.cpuBase + p.cpuSwing*wave + s.rng.NormFloat64()*p.cpuBase*0.1
+	if cont.spikeLeft > 0 {
+		cores += cont.spikeCPU
+		cont.spikeLeft -= elapsed
+	}
+	cores = math.Max(0, math.Min(cores, simulatedCPUs))
+	cont.cpuTotal += uint64(cores * float64(elapsed))
+
+	cont.leaked += uint64(float64(p.leakRate) * elapsed.Seconds())
+
+	seconds := elapsed.Seconds()
+	rx := s.rate(p.netRx, wave) * seconds
+	tx := s.rate(p.netTx, wave) * seconds
+	cont.rxBytes += uint64(rx)
+	cont.txBytes += uint64(tx)
+	cont.rxPackets += uint64(rx / packetSize)
+	cont.txPackets += uint64(tx / packetSize)
+
+	read := s.rate(p.diskRead, wave) * seconds
+	write := s.rate(p.diskWrite, wave) * seconds
+	cont.readBytes += uint64(read)
+	cont.writeBytes += uint64(write)
+	cont.readOps += uint64(read / 4096)
+	cont.writeOps += uint64(write / 4096)
+}
+
+// wave is a 0-1 daily-ish load curve with a per-container phase.
+func (s *Simulator) wave(cont *simContainer, now time.Time) float64 {
+	t := float64(now.UnixNano()) / float64(cont.profile.period)
+	return 0.5 + 0.5*math.Sin(2*math.Pi*t+cont.phase)
+}
+
+func (s *Simulator) rate(base, wave float64) float64 {
+	return math.Max(0, base*(0.4+0.6*wave)*(1+s.rng.NormFloat64()*0.1))
+}
+
+func (s *Simulator) chance(elapsed, every time.Duration) bool {
+	return s.rng.Float64() < elapsed.Seconds()/every.Seconds()
+}
+
+// memory is the anonymous and page cache memory a reading reports. The OOM check
+// uses it too, so a reading never reports usage beyond the limit.
+func (s *Simulator) memory(cont *simContainer, now time.Time) (anon, cache uint64) {
+	p := cont.profile
+	anon = p.memBase + cont.leaked + uint64(float64(p.memSwing)*s.wave(cont, now.Add(p.period/3)))
+	return anon, p.memBase / 4
+}
+
+// reading renders the container state as the daemon would report it on a
+// cgroup v2 host.
+func (s *Simulator) reading(cont *simContainer, now time.Time) docker.StatsJSON {
+	p := cont.profile
+	anon, cache := s.memory(cont, now)
+	cpu := container.CPUStats{
+		CPUUsage:    container.CPUUsage{TotalUsage: cont.cpuTotal},
+		SystemUsage: s.system,
+		OnlineCPUs:  simulatedCPUs,
+	}
+
+	reading := docker.StatsJSON{
+		Name: "/" + cont.name,
+		ID:   cont.id,
+		Stats: container.Stats{
+			Read:        now,
+			PreRead:     cont.prevRead,
+			CPUStats:    cpu,
+			PreCPUStats: cont.prevCPU,
+			MemoryStats: container.MemoryStats{
+				Usage: anon + cache,
+				Limit: p.memLimit,
+				Stats: map[string]uint64{
+					"anon":          anon,
+					"file":          cache,
+					"inactive_file": cache / 2,
+					"kernel":        anon / 50,
+				},
+			},
+			PidsStats: container.PidsStats{Current: p.pids},
+			BlkioStats: container.BlkioStats{
+				IoServiceBytesRecursive: []container.BlkioStatEntry{
+					{Major: 8, Op: "read", Value: cont.readBytes},
+					{Major: 8, Op: "write", Value: cont.writeBytes},
+				},
+				IoServicedRecursive: []container.BlkioStatEntry{
+					{Major: 8, Op: "read", Value: cont.readOps},
+					{Major: 8, Op: "write", Value: cont.writeOps},
+				},
+			},
+		},
+		Networks: map[string]container.NetworkStats{
+			"eth0": {
+				RxBytes:   cont.rxBytes,
+				TxBytes:   cont.txBytes,
+				RxPackets: cont.rxPackets,
+				TxPackets: cont.txPackets,
+			},
+		},
+	}
+	cont.prevCPU = cpu
+	cont.prevRead = now
+	return reading
+}
+
+func (s *Simulator) newContainer(p profile, created time.Time) *simContainer {
+	s.replicas[p.project+"/"+p.service]++
+	cont := &simContainer{
+		id:        s.newID(),
+		name:      fmt.Sprintf("%s-%s-%d", p.project, p.service, s.replicas[p.project+"/"+p.service]),
+		profile:   p,
+		phase:     s.rng.Float64() * 2 * math.Pi,
+		created:   created,
+		startedAt: created,
+	}
+	if p.health {
+		cont.health = "healthy"
+	}
+	if p.port != 0 {
+		cont.port = p.port
+		for s.ports[cont.port] {
+			cont.port++
+		}
+		s.ports[cont.port] = true
+	}
+	s.containers = append(s.containers, cont)
+	return cont
+}
+
+func (s *Simulator) newID() string {
+	const hex = "0123456789abcdef"
+	id := make([]byte, 64)
+	for i := range id {
+		id[i] = hex[s.rng.Intn(len(hex))]
+	}
+	return string(id)
+}
+
+// restart mirrors `docker restart`: the cgroup and network namespace are recreated,
+// so every counter starts again from zero.
+func (s *Simulator) restart(cont *simContainer, now time.Time, exitCode int) {
+	s.source.Emit(stats.ContainerEvent(events.ActionDie, cont.id, cont.name, map[string]string{"exitCode": strconv.Itoa(exitCode)}))
+
+	*cont = simContainer{
+		id:        cont.id,
+		name:      cont.name,
+		profile:   cont.profile,
+		phase:     cont.phase,
+		created:   cont.created,
+		startedAt: now,
+		restarts:  cont.restarts + 1,
+		port:      cont.port,
+	}
+	if cont.profile.health {
+		cont.health = "starting"
+	}
+	s.source.SetInspect(cont.id, s.inspect(cont))
+	s.source.SetStats(cont.id, s.reading(cont, now))
+
+	s.source.Emit(stats.ContainerEvent(events.ActionStart, cont.id, cont.name, nil))
+	s.source.Emit(stats.ContainerEvent(events.ActionRestart, cont.id, cont.name, nil))
+}
+
+// replace removes a container for good and starts a fresh one of a random kind.
+func (s *Simulator) replace(cont *simContainer, now time.Time) {
+	s.source.Stop(cont.id, 0)
+	s.source.Emit(stats.ContainerEvent(events.ActionDestroy, cont.id, cont.name, nil))
+	delete(s.ports, cont.port)
+	for i, existing := range s.containers {
+		if existing == cont {
+			s.containers = append(s.containers[:i], s.containers[i+1:]...)
+			break
+		}
+	}
+
+	next := s.newContainer(profiles[s.rng.Intn(len(profiles))], now)
+	s.source.SetInspect(next.id, s.inspect(next))
+	s.source.SetStats(next.id, s.reading(next, now))
+	s.source.Start(s.listing(next))
+}
+
+func (s *Simulator) flipHealth(cont *simContainer) {
+	switch cont.health {
+	case "healthy":
+		cont.health = "unhealthy"
+	default:
+		cont.health = "healthy"
+	}
+	s.source.SetInspect(cont.id, s.inspect(cont))
+	s.source.Emit(stats.ContainerEvent(events.Action("health_status: "+cont.health), cont.id, cont.name, nil))
+}
+
+func (s *Simulator) listing(cont *simContainer) docker.Container {
+	p := cont.profile
+	listing := docker.Container{
+		ID:      cont.id,
+		Names:   []string{"/" + cont.name},
+		Image:   p.image,
+		Created: cont.created.Unix(),
+		State:   "running",
+		Status:  "Up",
+		Labels: map[string]string{
+			"com.docker.compose.project": p.project,
+			"com.docker.compose.service": p.service,
+		},
+	}
+	if cont.port != 0 {
+		listing.Ports = []docker.Port{{IP: "0.0.0.0", PrivatePort: p.port, PublicPort: cont.port, Type: "tcp"}}
+	}
+	return listing
+}
+
+func (s *Simulator) inspect(cont *simContainer) docker.ContainerJSON {
+	state := &docker.ContainerState{
+		Status:    "running",
+		Running:   true,
+		StartedAt: cont.startedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if cont.health != "" {
+		state.Health = &docker.Health{Status: cont.health}
+	}
+	return docker.ContainerJSON{
+		ContainerJSONBase: &docker.ContainerJSONBase{
+			ID:           cont.id,
+			Name:         "/" + cont.name,
+			Image:        cont.profile.image,
+			RestartCount: cont.restarts,
+			State:        state,
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package simulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/stats"
+)
+
+func TestSimulatorKeepsContainersAndEmitsLifecycle(t *testing.T) {
+	source := stats.NewFakeSource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, _ := source.Events(ctx)
+
+	sim := New(source, Options{Containers: 6, Interval: time.Second, Seed: 42})
+
+	seen := make(map[events.Action]int)
+	now := sim.last
+	for i := 0; i < 200; i++ {
+		now = now.Add(30 * time.Second)
+		sim.step(now)
+
+		containers, err := source.ListContainers(ctx, "")
+		if err != nil {
+			t.Fatalf("ListContainers returned error: %v", err)
+		}
+		if len(containers) != 6 {
+			t.Fatalf("step %d: expected 6 containers, got %d", i, len(containers))
+		}
+		for _, cont := range containers {
+			reading, err := source.ContainerStats(ctx, cont.ID)
+			if err != nil {
+				t.Fatalf("ContainerStats returned error: %v", err)
+			}
+			cpu := reading.CPUStats.CPUUsage.TotalUsage - reading.PreCPUStats.CPUUsage.TotalUsage
+			system := reading.CPUStats.SystemUsage - reading.PreCPUStats.SystemUsage
+			if reading.PreCPUStats.SystemUsage != 0 && cpu > system {
+				t.Fatalf("container %s used more than the host: %d > %d", cont.Names[0], cpu, system)
+			}
+			if reading.MemoryStats.Usage == 0 || reading.MemoryStats.Usage > reading.MemoryStats.Limit {
+				t.Fatalf("container %s memory out of range: %+v", cont.Names[0], reading.MemoryStats)
+			}
+		}
+
+	drain:
+		for {
+			select {
+			case msg := <-msgs:
+				seen[msg.Action]++
+			default:
+				break drain
+			}
+		}
+	}
+
+	for _, action := range []events.Action{events.ActionStart, events.ActionDie, events.ActionRestart, events.ActionDestroy} {
+		if seen[action] == 0 {
+			t.Fatalf("expected %s events, saw %v", action, seen)
+		}
+	}
+}
+
+func TestSimulatorKillsLeakBeforeLimit(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		source := stats.NewFakeSource()
+		ctx, cancel := context.WithCancel(context.Background())
+		msgs, _ := source.Events(ctx)
+
+		sim := New(source, Options{Containers: len(profiles), Interval: time.Second, Seed: seed})
+		ooms := 0
+		now := sim.last
+		for i := 0; i < 100; i++ {
+			// Leak far faster than the profiles do, so every container reaches
+			// its limit within the run.
+			for _, cont := range sim.containers {
+				cont.leaked += cont.profile.memLimit / 40
+			}
+			now = now.Add(time.Second)
+			sim.step(now)
+
+			for _, cont := range sim.containers {
+				reading, err := source.ContainerStats(ctx, cont.id)
+				if err != nil {
+					t.Fatalf("ContainerStats returned error: %v", err)
+				}
+				if reading.MemoryStats.Usage > reading.MemoryStats.Limit {
+					t.Fatalf("seed %d: container %s uses %d of %d", seed, cont.name, reading.MemoryStats.Usage, reading.MemoryStats.Limit)
+				}
+			}
+
+		drain:
+			for {
+				select {
+				case msg := <-msgs:
+					if msg.Action == events.ActionOOM {
+						ooms++
+					}
+				default:
+					break drain
+				}
+			}
+		}
+		cancel()
+		if ooms == 0 {
+			t.Fatalf("seed %d: expected oom events", seed)
+		}
+	}
+}
+
+func TestSimulatorDeterministic(t *testing.T) {
+	first := New(stats.NewFakeSource(), Options{Containers: 3, Seed: 7})
+	second := New(stats.NewFakeSource(), Options{Containers: 3, Seed: 7})
+	for i := range first.containers {
+		if first.containers[i].id != second.containers[i].id || first.containers[i].name != second.containers[i].name {
+			t.Fatalf("same seed produced different containers: %s vs %s", first.containers[i].name, second.containers[i].name)
+		}
+	}
+}
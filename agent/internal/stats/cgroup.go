@@ -0,0 +1,375 @@
+package stats
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// Docker reports system CPU time in nanoseconds derived from /proc/stat ticks,
+// which the kernel always exposes at USER_HZ=100.
+const clockTicksPerSecond = 100
+
+// CgroupReader reads container stats straight from the cgroup v2 hierarchy instead
+// of asking dockerd for them, which keeps the daemon idle however many containers
+// are running. It produces the same StatsJSON the daemon would, so samples are
+// computed identically for both sources.
+type CgroupReader struct {
+	root     string
+	procRoot string
+
+	mu    sync.Mutex
+	paths map[string]string
+	prev  map[string]container.CPUStats
+}
+
+// NewCgroupReader checks that root is a cgroup v2 mount. procRoot is used for the
+// host CPU counters and for per-container network counters.
+func NewCgroupReader(root, procRoot string) (*CgroupReader, error) {
+	if root == "" {
+		root = "/sys/fs/cgroup"
+	}
+	if procRoot == "" {
+		procRoot = "/proc"
+	}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 hierarchy: %w", root, err)
+	}
+	return &CgroupReader{
+		root:     root,
+		procRoot: procRoot,
+		paths:    make(map[string]string),
+		prev:     make(map[string]container.CPUStats),
+	}, nil
+}
+
+// Read returns the current stats for a container. pid is the container's init
+// process as reported by inspect; it is used to locate cgroups outside the usual
+// Docker layouts and to read network counters, and may be 0 when unknown.
+func (r *CgroupReader) Read(id string, pid int) (docker.StatsJSON, error) {
+	dir, err := r.resolve(id, pid)
+	if err != nil {
+		return docker.StatsJSON{}, err
+	}
+
+	var stats docker.StatsJSON
+	stats.ID = id
+	stats.Read = time.Now()
+
+	cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return docker.StatsJSON{}, err
+	}
+	systemUsage, onlineCPUs, err := r.readSystemCPU()
+	if err != nil {
+		return docker.StatsJSON{}, err
+	}
+	stats.CPUStats = container.CPUStats{
+		CPUUsage: container.CPUUsage{
+			TotalUsage:        cpuStat["usage_usec"] * 1000,
+			UsageInUsermode:   cpuStat["user_usec"] * 1000,
+			UsageInKernelmode: cpuStat["system_usec"] * 1000,
+		},
+		SystemUsage: systemUsage,
+		OnlineCPUs:  onlineCPUs,
+		ThrottlingData: container.ThrottlingData{
+			Periods:          cpuStat["nr_periods"],
+			ThrottledPeriods: cpuStat["nr_throttled"],
+			ThrottledTime:    cpuStat["throttled_usec"] * 1000,
+		},
+	}
+
+	r.mu.Lock()
+	stats.PreCPUStats = r.prev[id]
+	r.prev[id] = stats.CPUStats
+	r.mu.Unlock()
+
+	if stats.MemoryStats.Usage, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		return docker.StatsJSON{}, err
+	}
+	if stats.MemoryStats.Stats, err = readKeyValues(filepath.Join(dir, "memory.stat")); err != nil {
+		return docker.StatsJSON{}, err
+	}
+	stats.MemoryStats.Limit = r.memoryLimit(dir)
+
+	if stats.BlkioStats, err = readIOStat(filepath.Join(dir, "io.stat")); err != nil {
+		return docker.StatsJSON{}, err
+	}
+
+	// pids.current is missing when the pids controller is not enabled for the group.
+	if current, err := readUint(filepath.Join(dir, "pids.current")); err == nil {
+		stats.PidsStats.Current = current
+		if limit, err := readUint(filepath.Join(dir, "pids.max")); err == nil {
+			stats.PidsStats.Limit = limit
+		}
+	}
+
+	if pid > 0 {
+		// The process may have exited between inspect and now; report no networks.
+		if networks, err := readNetDev(filepath.Join(r.procRoot, strconv.Itoa(pid), "net", "dev")); err == nil {
+			stats.Networks = networks
+		}
+	}
+
+	return stats, nil
+}
+
+// Forget drops the cached cgroup path and CPU baseline for a container.
+func (r *CgroupReader) Forget(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.paths, id)
+	delete(r.prev, id)
+}
+
+// resolve finds the container's cgroup directory. The systemd and cgroupfs
+// drivers use fixed layouts; anything else (rootless, custom --cgroup-parent) is
+// found through the init process's /proc/<pid>/cgroup entry.
+func (r *CgroupReader) resolve(id string, pid int) (string, error) {
+	r.mu.Lock()
+	cached, ok := r.paths[id]
+	r.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	candidates := []string{
+		filepath.Join(r.root, "system.slice", "docker-"+id+".scope"),
+		filepath.Join(r.root, "docker", id),
+	}
+	if pid > 0 {
+		if rel, err := r.processCgroup(pid); err == nil {
+			candidates = append(candidates, filepath.Join(r.root, rel))
+		}
+	}
+
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, "cpu.stat")); err != nil {
+			continue
+		}
+		r.mu.Lock()
+		r.paths[id] = dir
+		r.mu.Unlock()
+		return dir, nil
+	}
+	return "", fmt.Errorf("no cgroup found for container %s under %s", shortID(id), r.root)
+}
+
+// processCgroup returns the unified hierarchy path of a process. Paths outside the
+// reader's cgroup namespace show up as "/.." prefixes and cannot be used.
+func (r *CgroupReader) processCgroup(pid int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(r.procRoot, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		rel, ok := strings.CutPrefix(line, "0::")
+		if !ok {
+			continue
+		}
+		if rel == "/" || strings.Contains(rel, "..") {
+			return "", errors.New("cgroup path outside namespace")
+		}
+		return rel, nil
+	}
+	return "", errors.New("no unified cgroup entry")
+}
+
+func (r *CgroupReader) readSystemCPU() (uint64, uint32, error) {
+	file, err := os.Open(filepath.Join(r.procRoot, "stat"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var ticks uint64
+	var cpus uint32
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cpus++
+			continue
+		}
+		// Same fields as the daemon: user nice system idle iowait irq softirq.
+		for _, raw := range fields[1:min(len(fields), 8)] {
+			value, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("parse %s: %w", file.Name(), err)
+			}
+			ticks += value
+		}
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return 0, 0, errors.New("no aggregate cpu line in stat")
+	}
+	return ticks * uint64(time.Second) / clockTicksPerSecond, cpus, nil
+}
+
+// memoryLimit mirrors the daemon, which reports host memory for unlimited groups.
+func (r *CgroupReader) memoryLimit(dir string) uint64 {
+	if limit, err := readUint(filepath.Join(dir, "memory.max")); err == nil {
+		return limit
+	}
+	meminfo, err := readKeyValues(filepath.Join(r.procRoot, "meminfo"))
+	if err != nil {
+		return 0
+	}
+	return meminfo["MemTotal:"] * 1024
+}
+
+// readUint parses single-value cgroup files; "max" is reported as an error.
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readKeyValues parses flat-keyed files such as cpu.stat and memory.stat. Lines
+// with unparsable values are skipped.
+func readKeyValues(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = value
+	}
+	return values, nil
+}
+
+// readIOStat converts io.stat ("8:0 rbytes=1 wbytes=2 rios=3 wios=4 ...") into the
+// per-device read/write entries the daemon reports for cgroup v2.
+func readIOStat(path string) (container.BlkioStats, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// The io controller is optional; no file means no accounting.
+			return container.BlkioStats{}, nil
+		}
+		return container.BlkioStats{}, err
+	}
+
+	var blkio container.BlkioStats
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		majorRaw, minorRaw, ok := strings.Cut(fields[0], ":")
+		if !ok {
+			continue
+		}
+		major, err1 := strconv.ParseUint(majorRaw, 10, 64)
+		minor, err2 := strconv.ParseUint(minorRaw, 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, raw, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				continue
+			}
+			entry := container.BlkioStatEntry{Major: major, Minor: minor, Value: value}
+			switch key {
+			case "rbytes":
+				entry.Op = "read"
+				blkio.IoServiceBytesRecursive = append(blkio.IoServiceBytesRecursive, entry)
+			case "wbytes":
+				entry.Op = "write"
+				blkio.IoServiceBytesRecursive = append(blkio.IoServiceBytesRecursive, entry)
+			case "rios":
+				entry.Op = "read"
+				blkio.IoServicedRecursive = append(blkio.IoServicedRecursive, entry)
+			case "wios":
+				entry.Op = "write"
+				blkio.IoServicedRecursive = append(blkio.IoServicedRecursive, entry)
+			}
+		}
+	}
+	return blkio, nil
+}
+
+// readNetDev parses /proc/<pid>/net/dev, which reflects the network namespace of
+// that process. Loopback is left out, as it is in the daemon's stats.
+func readNetDev(path string) (map[string]container.NetworkStats, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	networks := make(map[string]container.NetworkStats)
+	for _, line := range strings.Split(string(data), "\n") {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(rest)
+		if name == "lo" || len(fields) < 16 {
+			continue
+		}
+		var counters [16]uint64
+		valid := true
+		for i := range counters {
+			value, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			counters[i] = value
+		}
+		if !valid {
+			continue
+		}
+		networks[name] = container.NetworkStats{
+			RxBytes:   counters[0],
+			RxPackets: counters[1],
+			RxErrors:  counters[2],
+			RxDropped: counters[3],
+			TxBytes:   counters[8],
+			TxPackets: counters[9],
+			TxErrors:  counters[10],
+			TxDropped: counters[11],
+		}
+	}
+	return networks, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,183 @@
+package stats
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func fakeCgroupTree(t *testing.T, id string) (string, string, string) {
+	t.Helper()
+	root := t.TempDir()
+	proc := t.TempDir()
+	dir := filepath.Join(root, "system.slice", "docker-"+id+".scope")
+
+	writeTestFile(t, filepath.Join(root, "cgroup.controllers"), "cpu io memory pids\n")
+	writeTestFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 1000000\nuser_usec 600000\nsystem_usec 400000\nnr_periods 0\nnr_throttled 0\nthrottled_usec 0\n")
+	writeTestFile(t, filepath.Join(dir, "memory.current"), "104857600\n")
+	writeTestFile(t, filepath.Join(dir, "memory.max"), "max\n")
+	writeTestFile(t, filepath.Join(dir, "memory.stat"), "anon 52428800\nfile 41943040\ninactive_file 20971520\nkernel 1048576\n")
+	writeTestFile(t, filepath.Join(dir, "io.stat"), "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n")
+	writeTestFile(t, filepath.Join(dir, "pids.current"), "7\n")
+	writeTestFile(t, filepath.Join(dir, "pids.max"), "max\n")
+
+	writeTestFile(t, filepath.Join(proc, "stat"), "cpu  1000 0 1000 8000 0 0 0 0 0 0\ncpu0 500 0 500 4000 0 0 0 0 0 0\ncpu1 500 0 500 4000 0 0 0 0 0 0\n")
+	writeTestFile(t, filepath.Join(proc, "meminfo"), "MemTotal:        8000 kB\n")
+	writeTestFile(t, filepath.Join(proc, "42", "net", "dev"), `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    2048      10    1    2    0     0          0         0     1024       5    0    3    0     0       0          0
+`)
+	return root, proc, dir
+}
+
+func TestCgroupReaderRead(t *testing.T) {
+	id := "abc123"
+	root, proc, dir := fakeCgroupTree(t, id)
+
+	reader, err := NewCgroupReader(root, proc)
+	if err != nil {
+		t.Fatalf("NewCgroupReader returned error: %v", err)
+	}
+
+	stats, err := reader.Read(id, 42)
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if stats.PreCPUStats.SystemUsage != 0 {
+		t.Fatalf("expected no previous reading on first read")
+	}
+	if stats.CPUStats.CPUUsage.TotalUsage != 1_000_000_000 || stats.CPUStats.OnlineCPUs != 2 {
+		t.Fatalf("unexpected cpu stats: %+v", stats.CPUStats)
+	}
+	// 10000 ticks at 100Hz.
+	if stats.CPUStats.SystemUsage != 100_000_000_000 {
+		t.Fatalf("unexpected system usage: %d", stats.CPUStats.SystemUsage)
+	}
+	if stats.MemoryStats.Limit != 8000*1024 {
+		t.Fatalf("expected host memory as limit, got %d", stats.MemoryStats.Limit)
+	}
+	if len(stats.Networks) != 1 || stats.Networks["eth0"].RxBytes != 2048 || stats.Networks["eth0"].TxDropped != 3 {
+		t.Fatalf("unexpected networks: %+v", stats.Networks)
+	}
+
+	// One second of container CPU over 20s of summed CPU time (10s wall on 2 CPUs).
+	writeTestFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 2000000\n")
+	writeTestFile(t, filepath.Join(proc, "stat"), "cpu  1500 0 1500 9000 0 0 0 0 0 0\ncpu0 0\ncpu1 0\n")
+	stats, err = reader.Read(id, 42)
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+
+	sample := convertStats(docker.Container{ID: id, Names: []string{"/web"}}, stats)
+	if diff := sample.CPUPct - 10; diff > 0.0001 || diff < -0.0001 {
+		t.Fatalf("unexpected cpu pct: %f", sample.CPUPct)
+	}
+	if sample.MemUsageBytes != 104857600 || sample.MemBytes != 104857600-20971520 || sample.MemKernelBytes != 1048576 {
+		t.Fatalf("unexpected memory: %+v", sample)
+	}
+	if sample.BlkioReadBytes != 4096 || sample.BlkioWriteBytes != 8192 || sample.BlkioReadOps != 1 || sample.BlkioWriteOps != 2 {
+		t.Fatalf("unexpected blkio: %+v", sample)
+	}
+	if sample.PIDs != 7 {
+		t.Fatalf("unexpected pids: %d", sample.PIDs)
+	}
+}
+
+func TestCgroupReaderResolveFromPid(t *testing.T) {
+	root := t.TempDir()
+	proc := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "cgroup.controllers"), "cpu memory\n")
+	writeTestFile(t, filepath.Join(root, "custom.slice", "web", "cpu.stat"), "usage_usec 1\n")
+	writeTestFile(t, filepath.Join(proc, "7", "cgroup"), "0::/custom.slice/web\n")
+
+	reader, err := NewCgroupReader(root, proc)
+	if err != nil {
+		t.Fatalf("NewCgroupReader returned error: %v", err)
+	}
+	dir, err := reader.resolve("abc", 7)
+	if err != nil {
+		t.Fatalf("resolve returned error: %v", err)
+	}
+	if dir != filepath.Join(root, "custom.slice", "web") {
+		t.Fatalf("unexpected cgroup dir: %s", dir)
+	}
+
+	if _, err := reader.resolve("missing", 0); err == nil {
+		t.Fatalf("expected error for unknown container")
+	}
+}
+
+func TestNewCgroupReaderRequiresV2(t *testing.T) {
+	if _, err := NewCgroupReader(t.TempDir(), t.TempDir()); err == nil {
+		t.Fatalf("expected error for a hierarchy without cgroup.controllers")
+	}
+}
+
+func TestCollectorKeepsCgroupBaselineAcrossRestart(t *testing.T) {
+	id := "abc123"
+	root, proc, dir := fakeCgroupTree(t, id)
+	reader, err := NewCgroupReader(root, proc)
+	if err != nil {
+		t.Fatalf("NewCgroupReader returned error: %v", err)
+	}
+	source := NewFakeSource()
+	source.SetContainer(docker.Container{ID: id, Names: []string{"/web"}, State: "running"})
+	collector := NewCollector(source, slog.New(slog.NewTextHandler(io.Discard, nil)), Options{
+		PollInterval: time.Hour,
+		Cgroup:       reader,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hasBaseline := func(usage uint64) func() bool {
+		return func() bool {
+			reader.mu.Lock()
+			defer reader.mu.Unlock()
+			prev, ok := reader.prev[id]
+			return ok && (usage == 0 || prev.CPUUsage.TotalUsage == usage)
+		}
+	}
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	collector.refreshContainer(ctx, id, false)
+	waitFor("the first reading", hasBaseline(1_000_000_000))
+
+	// The new watcher reads once and then waits an hour, so a baseline the old
+	// watcher forgets on its way out is not stored again.
+	writeTestFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 2000000\n")
+	collector.refreshContainer(ctx, id, true)
+	waitFor("the old watcher to return", func() bool { return collector.running.Load() == 1 })
+	waitFor("the new watcher's baseline", hasBaseline(2_000_000_000))
+
+	collector.dropContainer(id)
+	waitFor("the watcher to return", func() bool { return collector.running.Load() == 0 })
+	if hasBaseline(0)() {
+		t.Fatalf("expected the baseline to be forgotten with the container")
+	}
+}
@@ -2,55 +2,148 @@ package stats
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	docker "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
 
 	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
 )
 
+const (
+	// StatsModePoll requests a single stats frame per container every poll interval.
+	StatsModePoll = "poll"
+	// StatsModeStream keeps one long-lived streaming stats request per container.
+	StatsModeStream = "stream"
+)
+
+const defaultReconcileInterval = 30 * time.Second
+
+const (
+	streamRetryMin = 500 * time.Millisecond
+	streamRetryMax = 30 * time.Second
+)
+
+// HostReader samples host-wide metrics for the batch host section.
+type HostReader interface {
+	Read() (types.HostMetrics, error)
+}
+
+type Options struct {
+	PollInterval time.Duration
+	AgentID      string
+	AgentLabel   string
+	WorkerLimit  int
+	// NetPerInterface adds a per-interface breakdown to every sample.
+	NetPerInterface bool
+	// StatsMode selects StatsModePoll (default) or StatsModeStream.
+	StatsMode string
+	// LabelAllowlist lists the container label keys copied into samples; entries
+	// ending in "*" match by prefix. Empty means no labels are sent.
+	LabelAllowlist []string
+	// ComposeRollups adds per compose project and service totals to every batch.
+	ComposeRollups bool
+	// GroupByLabels adds per-value totals for each listed label key.
+	GroupByLabels []string
+	// Host, when set, is sampled every poll interval and published as the batch
+	// host section.
+	Host HostReader
+	// Cgroup, when set, replaces the daemon stats API: samples are read from the
+	// cgroup v2 hierarchy and Docker is only used for discovery. It implies poll mode.
+	Cgroup *CgroupReader
+	// FlushNewContainers publishes a batch as soon as a new container reports its
+	// first sample instead of waiting for the next tick.
+	FlushNewContainers bool
+	// ReconcileInterval is how often the full container list is re-read as a safety
+	// net for missed Docker events.
+	ReconcileInterval time.Duration
+}
+
 type Collector struct {
-	client       *client.Client
-	log          *slog.Logger
-	pollInterval time.Duration
-	agentID      string
-	agentLabel   string
-	workerLimit  int
+	source            Source
+	log               *slog.Logger
+	pollInterval      time.Duration
+	agentID           string
+	agentLabel        string
+	workerLimit       int
+	netPerInterface   bool
+	statsMode         string
+	reconcileInterval time.Duration
+	labelAllowlist    labelAllowlist
+	composeRollups    bool
+	groupByLabels     []string
+	host              HostReader
+	cgroup            *CgroupReader
+	flushNew          bool
 
 	mu         sync.RWMutex
 	watchersMu sync.Mutex
 	sequence   uint64
 	lastBatch  *types.ContainerStatsBatch
 	lastSentAt time.Time
+	// dirty is set when samples or host metrics changed since the last batch.
+	dirty bool
+	flush chan struct{}
+
+	hostMetrics *types.HostMetrics
 
 	samples   map[string]types.ContainerResourceSample
+	meta      map[string]containerMeta
+	netRates  *netRateTracker
 	watchers  map[string]context.CancelFunc
 	sampleSem chan struct{}
+
+	// running counts watcher goroutines, including stopped ones that have not
+	// returned yet.
+	running atomic.Int32
 }
 
-func NewCollector(cli *client.Client, logger *slog.Logger, pollInterval time.Duration, agentID, agentLabel string, workerLimit int) *Collector {
+// NewCollector builds a collector reading from source; NewDockerSource provides
+// the Docker Engine implementation.
+func NewCollector(source Source, logger *slog.Logger, opts Options) *Collector {
+	workerLimit := opts.WorkerLimit
 	if workerLimit <= 0 {
 		workerLimit = 1
 	}
+	statsMode := opts.StatsMode
+	if statsMode == "" || opts.Cgroup != nil {
+		statsMode = StatsModePoll
+	}
+	if _, ok := source.(StatsStreamer); !ok {
+		statsMode = StatsModePoll
+	}
+	reconcileInterval := opts.ReconcileInterval
+	if reconcileInterval <= 0 {
+		reconcileInterval = defaultReconcileInterval
+	}
 	return &Collector{
-		client:       cli,
-		log:          logger,
-		pollInterval: pollInterval,
-		agentID:      agentID,
-		agentLabel:   agentLabel,
-		workerLimit:  workerLimit,
-		samples:      make(map[string]types.ContainerResourceSample),
-		watchers:     make(map[string]context.CancelFunc),
-		sampleSem:    make(chan struct{}, workerLimit),
+		source:            source,
+		log:               logger,
+		pollInterval:      opts.PollInterval,
+		agentID:           opts.AgentID,
+		agentLabel:        opts.AgentLabel,
+		workerLimit:       workerLimit,
+		netPerInterface:   opts.NetPerInterface,
+		statsMode:         statsMode,
+		reconcileInterval: reconcileInterval,
+		labelAllowlist:    labelAllowlist(opts.LabelAllowlist),
+		composeRollups:    opts.ComposeRollups,
+		groupByLabels:     opts.GroupByLabels,
+		host:              opts.Host,
+		cgroup:            opts.Cgroup,
+		flushNew:          opts.FlushNewContainers,
+		flush:             make(chan struct{}, 1),
+		samples:           make(map[string]types.ContainerResourceSample),
+		meta:              make(map[string]containerMeta),
+		netRates:          newNetRateTracker(),
+		watchers:          make(map[string]context.CancelFunc),
+		sampleSem:         make(chan struct{}, workerLimit),
 	}
 }
 
@@ -64,28 +157,89 @@ func (c *Collector) LastBatch() *types.ContainerStatsBatch {
 	return &clone
 }
 
-func (c *Collector) Collect(ctx context.Context, out chan<- types.ContainerStatsBatch) {
-	ticker := time.NewTicker(c.pollInterval)
-	defer ticker.Stop()
+// Collect samples running containers into out and forwards container lifecycle
+// changes to notify, which may be nil.
+func (c *Collector) Collect(ctx context.Context, out chan<- types.ContainerStatsBatch, notify chan<- types.ContainerEventMessage) {
+	reconcile := time.NewTicker(c.reconcileInterval)
+	defer reconcile.Stop()
+
+	// Once Collect returns nothing writes to out any more.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if c.host != nil {
+		wg.Go(func() { c.sampleHost(ctx) })
+	}
+	wg.Go(func() { c.publishLoop(ctx, out) })
+
+	// Subscribe before the initial listing so no start/stop falls in between.
+	events, errs := c.source.Events(ctx)
 
 	// Collect immediately at startup
 	c.collectOnce(ctx, out, true)
 
+	var resubscribe <-chan time.Time
+	retry := streamRetryMin
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-reconcile.C:
+			c.collectOnce(ctx, out, false)
+		case msg := <-events:
+			retry = streamRetryMin
+			c.handleEvent(ctx, notify, msg)
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return
+			}
+			c.log.Warn("docker events stream failed",
+				slog.String("error", err.Error()),
+				slog.Duration("retry_in", retry),
+			)
+			events, errs = nil, nil
+			resubscribe = time.After(retry)
+			retry = min(retry*2, streamRetryMax)
+		case <-resubscribe:
+			resubscribe = nil
+			events, errs = c.source.Events(ctx)
+			// Anything that happened while disconnected is only visible in a full listing.
 			c.collectOnce(ctx, out, false)
 		}
 	}
 }
 
+func (c *Collector) sampleHost(ctx context.Context) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		metrics, err := c.host.Read()
+		if err != nil {
+			// Warn once per failure streak; the proc root is usually just misconfigured.
+			if !failing {
+				c.log.Warn("failed to read host metrics", slog.String("error", err.Error()))
+				failing = true
+			}
+		} else {
+			failing = false
+			c.mu.Lock()
+			c.hostMetrics = &metrics
+			c.dirty = true
+			c.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (c *Collector) collectOnce(ctx context.Context, out chan<- types.ContainerStatsBatch, startup bool) {
-	containers, err := c.client.ContainerList(ctx, container.ListOptions{
-		Filters: filters.NewArgs(),
-		All:     false,
-	})
+	containers, err := c.source.ListContainers(ctx, "")
 	if err != nil {
 		c.log.Warn("failed to list containers", slog.String("error", err.Error()))
 		if cached := c.LastBatch(); cached != nil {
@@ -99,51 +253,85 @@ func (c *Collector) collectOnce(ctx context.Context, out chan<- types.ContainerS
 
 	active := make(map[string]docker.Container, len(containers))
 	for _, cont := range containers {
+		if isPaused(cont) {
+			continue
+		}
 		active[cont.ID] = cont
 	}
 
-	c.syncWatchers(ctx, out, active)
+	c.syncWatchers(ctx, active)
 
 	if startup {
 		c.log.Info("collector initialised", slog.Int("container_count", len(containers)))
 	}
 }
 
-func (c *Collector) syncWatchers(ctx context.Context, out chan<- types.ContainerStatsBatch, active map[string]docker.Container) {
+func (c *Collector) syncWatchers(ctx context.Context, active map[string]docker.Container) {
 	c.watchersMu.Lock()
 	// Start watchers for new containers
-	for id, cont := range active {
-		if _, ok := c.watchers[id]; ok {
-			continue
-		}
-		watchCtx, cancel := context.WithCancel(ctx)
-		c.watchers[id] = cancel
-		go c.runWatcher(watchCtx, out, cont)
+	for _, cont := range active {
+		c.startWatcherLocked(ctx, cont)
 	}
 
 	// Stop watchers for containers that disappeared
-	for id, cancel := range c.watchers {
+	for id := range c.watchers {
 		if _, ok := active[id]; ok {
 			continue
 		}
-		cancel()
-		delete(c.watchers, id)
+		c.stopWatcherLocked(id)
 	}
 	c.watchersMu.Unlock()
 
-	if batch, removed := c.removeMissingSamples(active); removed {
-		c.dispatchBatch(ctx, out, batch)
+	c.removeMissingSamples(active)
+}
+
+func (c *Collector) startWatcherLocked(ctx context.Context, cont docker.Container) {
+	if _, ok := c.watchers[cont.ID]; ok {
+		return
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	c.watchers[cont.ID] = cancel
+
+	c.mu.Lock()
+	c.meta[cont.ID] = newContainerMeta(cont, c.labelAllowlist)
+	c.mu.Unlock()
+
+	c.running.Add(1)
+	go func() {
+		defer c.running.Add(-1)
+		c.runWatcher(watchCtx, cont)
+	}()
+}
+
+func (c *Collector) stopWatcherLocked(id string) {
+	if cancel, ok := c.watchers[id]; ok {
+		cancel()
+		delete(c.watchers, id)
 	}
+
+	c.mu.Lock()
+	delete(c.meta, id)
+	c.mu.Unlock()
 }
 
-func (c *Collector) runWatcher(ctx context.Context, out chan<- types.ContainerStatsBatch, cont docker.Container) {
+func (c *Collector) runWatcher(ctx context.Context, cont docker.Container) {
 	logger := c.log.With(
 		slog.String("container_id", cont.ID),
 		slog.String("container_name", firstName(cont.Names)),
 	)
 
+	c.inspectContainer(ctx, cont.ID)
+	if c.cgroup != nil {
+		defer c.forgetCgroup(cont.ID)
+	}
+
+	if c.statsMode == StatsModeStream {
+		c.streamWatcher(ctx, cont, logger)
+		return
+	}
+
 	// Send first sample immediately for low latency updates
-	c.sampleContainer(ctx, out, cont, logger)
+	c.sampleContainer(ctx, cont, logger)
 
 	ticker := time.NewTicker(c.pollInterval)
 	defer ticker.Stop()
@@ -153,12 +341,12 @@ func (c *Collector) runWatcher(ctx context.Context, out chan<- types.ContainerSt
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			c.sampleContainer(ctx, out, cont, logger)
+			c.sampleContainer(ctx, cont, logger)
 		}
 	}
 }
 
-func (c *Collector) sampleContainer(ctx context.Context, out chan<- types.ContainerStatsBatch, cont docker.Container, logger *slog.Logger) {
+func (c *Collector) sampleContainer(ctx context.Context, cont docker.Container, logger *slog.Logger) {
 	select {
 	case c.sampleSem <- struct{}{}:
 	case <-ctx.Done():
@@ -166,92 +354,236 @@ func (c *Collector) sampleContainer(ctx context.Context, out chan<- types.Contai
 	}
 	defer func() { <-c.sampleSem }()
 
-	stats, err := c.fetchStats(ctx, cont.ID)
+	stats, err := c.readStats(ctx, cont.ID)
 	if err != nil {
 		logger.Debug("failed to fetch container stats", slog.String("error", err.Error()))
 		return
 	}
 
-	batch := c.upsertSample(cont, stats)
-	c.dispatchBatch(ctx, out, batch)
+	c.recordSample(ctx, cont, stats)
 }
 
-func (c *Collector) upsertSample(cont docker.Container, stats docker.StatsJSON) types.ContainerStatsBatch {
+// streamWatcher holds a single streaming stats request open for the container and
+// reconnects with exponential backoff whenever the daemon closes it.
+func (c *Collector) streamWatcher(ctx context.Context, cont docker.Container, logger *slog.Logger) {
+	retry := streamRetryMin
+	for {
+		frames, err := c.streamStats(ctx, cont)
+		if ctx.Err() != nil {
+			return
+		}
+		if frames > 0 {
+			retry = streamRetryMin
+		}
+		logger.Debug("stats stream closed, reconnecting",
+			slog.String("error", err.Error()),
+			slog.Duration("retry_in", retry),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, streamRetryMax)
+	}
+}
+
+// streamStats consumes frames until the stream fails and reports how many were
+// decoded. Docker emits roughly one frame per second; frames arriving faster than
+// the poll interval are skipped so the configured cadence is still honoured.
+func (c *Collector) streamStats(ctx context.Context, cont docker.Container) (int, error) {
+	// Only connection setup competes for worker slots; an open stream costs nothing.
+	select {
+	case c.sampleSem <- struct{}{}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	stream, err := c.source.(StatsStreamer).StreamStats(ctx, cont.ID)
+	<-c.sampleSem
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	frames := 0
+	var lastSample time.Time
+	for {
+		stats, err := stream.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("stats stream ended")
+			}
+			return frames, err
+		}
+		frames++
+
+		now := time.Now()
+		if now.Sub(lastSample) < c.pollInterval {
+			continue
+		}
+		lastSample = now
+
+		c.recordSample(ctx, cont, stats)
+	}
+}
+
+// recordSample stores a reading for the next batch, asking for an early flush when
+// it is the container's first one and FlushNewContainers is set. ctx is the
+// watcher's; readings that arrive after it was stopped are discarded.
+func (c *Collector) recordSample(ctx context.Context, cont docker.Container, stats docker.StatsJSON) {
+	if first := c.upsertSample(ctx, cont, stats); first && c.flushNew {
+		select {
+		case c.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// upsertSample stores the sample and reports whether the container had none yet.
+func (c *Collector) upsertSample(ctx context.Context, cont docker.Container, stats docker.StatsJSON) bool {
 	sample := convertStats(cont, stats)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.samples[sample.ID] = sample
-	c.sequence++
+	// Watchers are cancelled before their samples are removed under c.mu, so
+	// checking here rather than before locking keeps a reading that was in
+	// flight when the container was dropped from bringing it back.
+	if ctx.Err() != nil {
+		return false
+	}
 
-	batch := c.snapshotLocked(time.Now().UTC())
-	batch.Sequence = c.sequence
-	c.lastBatch = &batch
-	c.lastSentAt = batch.SentAt
+	readAt := stats.Read
+	if readAt.IsZero() {
+		readAt = time.Now()
+	}
+	rates, ifaces := c.netRates.observe(sample.ID, readAt, stats.Networks)
+	rates.apply(&sample)
+	if c.netPerInterface {
+		sample.NetInterfaces = ifaces
+	}
+	if meta, ok := c.meta[sample.ID]; ok {
+		meta.apply(&sample, time.Now())
+	}
+
+	_, seen := c.samples[sample.ID]
+	c.samples[sample.ID] = sample
+	c.dirty = true
 
 	c.log.Debug("collected sample",
-		slog.Uint64("sequence", batch.Sequence),
-		slog.Int("containers", len(batch.Containers)),
-		slog.Float64("cpu_pct", batch.AgentMetrics.CPUPct),
-		slog.Uint64("mem_bytes", batch.AgentMetrics.MemBytes),
+		slog.String("container_id", sample.ID),
+		slog.Float64("cpu_pct", sample.CPUPct),
+		slog.Uint64("mem_bytes", sample.MemBytes),
 	)
 
-	return batch
+	return !seen
+}
+
+func (c *Collector) removeMissingSamples(active map[string]docker.Container) {
+	c.removeSamples(func(id string) bool {
+		_, ok := active[id]
+		return !ok
+	})
 }
 
-func (c *Collector) removeMissingSamples(active map[string]docker.Container) (types.ContainerStatsBatch, bool) {
+func (c *Collector) removeSamples(drop func(id string) bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if len(c.samples) == 0 {
-		return types.ContainerStatsBatch{}, false
-	}
-
-	removed := false
 	for id := range c.samples {
-		if _, ok := active[id]; ok {
+		if !drop(id) {
 			continue
 		}
 		delete(c.samples, id)
-		removed = true
+		c.netRates.forget(id)
+		c.dirty = true
 	}
+}
 
-	if !removed {
-		return types.ContainerStatsBatch{}, false
+// publishLoop sends one batch per poll interval carrying every change since the
+// previous one, however many containers reported in between. With N containers
+// this keeps traffic at one O(N) snapshot per tick instead of N of them.
+func (c *Collector) publishLoop(ctx context.Context, out chan<- types.ContainerStatsBatch) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.flush:
+		}
+		if batch, ok := c.takeBatch(); ok {
+			c.dispatchBatch(ctx, out, batch)
+		}
 	}
+}
 
+// takeBatch snapshots the current state if anything changed since the last batch.
+func (c *Collector) takeBatch() (types.ContainerStatsBatch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return types.ContainerStatsBatch{}, false
+	}
+	c.dirty = false
 	c.sequence++
+
 	batch := c.snapshotLocked(time.Now().UTC())
 	batch.Sequence = c.sequence
 	c.lastBatch = &batch
 	c.lastSentAt = batch.SentAt
 
+	c.log.Debug("publishing batch",
+		slog.Uint64("sequence", batch.Sequence),
+		slog.Int("containers", len(batch.Containers)),
+		slog.Float64("cpu_pct", batch.AgentMetrics.CPUPct),
+		slog.Uint64("mem_bytes", batch.AgentMetrics.MemBytes),
+	)
+
 	return batch, true
 }
 
 func (c *Collector) snapshotLocked(sentAt time.Time) types.ContainerStatsBatch {
 	containers := make([]types.ContainerResourceSample, 0, len(c.samples))
-	var totalCPU float64
-	var totalMem uint64
+	var summary types.AgentMetricsSummary
+	groups := newGroupRollups()
 
-	for _, sample := range c.samples {
+	for id, sample := range c.samples {
 		containers = append(containers, sample)
-		totalCPU += sample.CPUPct
-		totalMem += sample.MemBytes
+		groups.add(sample, c.meta[id].allLabels, c.composeRollups, c.groupByLabels)
+		summary.CPUPct += sample.CPUPct
+		summary.CPUHostPct += sample.CPUHostPct
+		summary.CPUCores += sample.CPUCores
+		summary.MemBytes += sample.MemBytes
+		summary.MemUsageBytes += sample.MemUsageBytes
+		summary.MemCacheBytes += sample.MemCacheBytes
+		summary.BlkioReadBytes += sample.BlkioReadBytes
+		summary.BlkioWriteBytes += sample.BlkioWriteBytes
+		summary.BlkioReadOps += sample.BlkioReadOps
+		summary.BlkioWriteOps += sample.BlkioWriteOps
+		summary.NetRxBytesPerSec += sample.NetRxBytesPerSec
+		summary.NetTxBytesPerSec += sample.NetTxBytesPerSec
+		summary.NetRxPacketsPerSec += sample.NetRxPacketsPerSec
+		summary.NetTxPacketsPerSec += sample.NetTxPacketsPerSec
 	}
-
-	return types.ContainerStatsBatch{
-		Type:       "container_stats_batch",
-		AgentID:    c.agentID,
-		AgentLabel: c.agentLabel,
-		SentAt:     sentAt,
-		Containers: containers,
-		AgentMetrics: types.AgentMetricsSummary{
-			CPUPct:   clamp(totalCPU, 0, 100),
-			MemBytes: totalMem,
-		},
+	// Rounding across containers can push the host share marginally past 100.
+	summary.CPUHostPct = clamp(summary.CPUHostPct, 0, 100)
+
+	batch := types.ContainerStatsBatch{
+		Type:         "container_stats_batch",
+		AgentID:      c.agentID,
+		AgentLabel:   c.agentLabel,
+		SentAt:       sentAt,
+		Containers:   containers,
+		AgentMetrics: summary,
+		Host:         c.hostMetrics,
 	}
+	groups.apply(&batch)
+	return batch
 }
 
 func (c *Collector) dispatchBatch(ctx context.Context, out chan<- types.ContainerStatsBatch, batch types.ContainerStatsBatch) {
@@ -267,36 +599,39 @@ func (c *Collector) dispatchBatch(ctx context.Context, out chan<- types.Containe
 	}
 }
 
-func (c *Collector) fetchStats(ctx context.Context, containerID string) (docker.StatsJSON, error) {
-	requestCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	resp, err := c.client.ContainerStats(requestCtx, containerID, false)
-	if err != nil {
-		return docker.StatsJSON{}, err
+// forgetCgroup drops the cgroup reader's state for a container once its watcher
+// has returned, so no read of that watcher can store it again. A restart may
+// already have registered a new watcher for the id, which keeps the state.
+func (c *Collector) forgetCgroup(id string) {
+	c.watchersMu.Lock()
+	defer c.watchersMu.Unlock()
+	if _, ok := c.watchers[id]; !ok {
+		c.cgroup.Forget(id)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.Body == nil {
-		return docker.StatsJSON{}, errors.New("nil stats body")
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return docker.StatsJSON{}, err
+// readStats takes a one-shot reading from the cgroup hierarchy when configured,
+// otherwise from the daemon.
+func (c *Collector) readStats(ctx context.Context, id string) (docker.StatsJSON, error) {
+	if c.cgroup == nil {
+		return c.fetchStats(ctx, id)
 	}
+	c.mu.RLock()
+	pid := c.meta[id].pid
+	c.mu.RUnlock()
+	return c.cgroup.Read(id, pid)
+}
 
-	var stats docker.StatsJSON
-	if err := json.Unmarshal(data, &stats); err != nil {
-		return docker.StatsJSON{}, err
-	}
+func (c *Collector) fetchStats(ctx context.Context, containerID string) (docker.StatsJSON, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	return stats, nil
+	return c.source.ContainerStats(requestCtx, containerID)
 }
 
 func convertStats(cont docker.Container, stats docker.StatsJSON) types.ContainerResourceSample {
-	cpuPct := calculateCPUPercent(stats)
-	memUsage := stats.MemoryStats.Usage
+	cpu := calculateCPU(stats)
+	mem := calculateMemory(stats.MemoryStats)
 	memLimit := stats.MemoryStats.Limit
 	if memLimit == 0 {
 		memLimit = 1
@@ -309,14 +644,106 @@ func convertStats(cont docker.Container, stats docker.StatsJSON) types.Container
 		}
 	}
 
+	blkio := calculateBlkio(stats.BlkioStats)
+
 	return types.ContainerResourceSample{
-		ID:            cont.ID,
-		Name:          firstName(cont.Names),
-		CPUPct:        cpuPct,
-		MemBytes:      uint64(memUsage),
-		MemLimitBytes: uint64(memLimit),
-		NetIOBytes:    netIO,
+		ID:              cont.ID,
+		Name:            firstName(cont.Names),
+		CPUPct:          cpu.pct,
+		CPUHostPct:      cpu.hostPct,
+		CPUCores:        cpu.cores,
+		OnlineCPUs:      cpu.onlineCPUs,
+		MemBytes:        mem.workingSet,
+		MemLimitBytes:   uint64(memLimit),
+		MemUsageBytes:   mem.usage,
+		MemRSSBytes:     mem.rss,
+		MemCacheBytes:   mem.cache,
+		MemSwapBytes:    mem.swap,
+		MemKernelBytes:  mem.kernel,
+		NetIOBytes:      netIO,
+		BlkioReadBytes:  blkio.readBytes,
+		BlkioWriteBytes: blkio.writeBytes,
+		BlkioReadOps:    blkio.readOps,
+		BlkioWriteOps:   blkio.writeOps,
+		PIDs:            stats.PidsStats.Current,
+	}
+}
+
+type memoryUsage struct {
+	usage      uint64
+	workingSet uint64
+	rss        uint64
+	cache      uint64
+	swap       uint64
+	kernel     uint64
+}
+
+// calculateMemory mirrors the Docker CLI: the working set is usage minus inactive
+// page cache, which the kernel can reclaim without pressure. cgroup v1 exposes the
+// hierarchical "total_*" keys, cgroup v2 only the flat memory.stat names. Neither
+// has the whole breakdown: v1 memory.stat has no kernel memory and v2 has no swap
+// usage, so kernel is 0 on v1 and swap is 0 on v2.
+func calculateMemory(stats container.MemoryStats) memoryUsage {
+	mem := memoryUsage{
+		usage:      stats.Usage,
+		workingSet: stats.Usage,
+	}
+
+	if inactive, cgroupV1 := stats.Stats["total_inactive_file"]; cgroupV1 {
+		if inactive < stats.Usage {
+			mem.workingSet = stats.Usage - inactive
+		}
+		mem.rss = stats.Stats["total_rss"]
+		mem.cache = stats.Stats["total_cache"]
+		mem.swap = stats.Stats["total_swap"]
+		return mem
+	}
+
+	if inactive := stats.Stats["inactive_file"]; inactive < stats.Usage {
+		mem.workingSet = stats.Usage - inactive
+	}
+	mem.rss = stats.Stats["anon"]
+	mem.cache = stats.Stats["file"]
+	// Kernels before 5.18 do not report the "kernel" total, so add up its largest parts.
+	if kernel, ok := stats.Stats["kernel"]; ok {
+		mem.kernel = kernel
+	} else {
+		mem.kernel = stats.Stats["kernel_stack"] + stats.Stats["slab"] + stats.Stats["sock"]
 	}
+	return mem
+}
+
+type blkioTotals struct {
+	readBytes  uint64
+	writeBytes uint64
+	readOps    uint64
+	writeOps   uint64
+}
+
+// calculateBlkio sums read/write entries across devices. cgroup v1 reports ops as
+// "Read"/"Write" alongside aggregate "Total"/"Sync"/"Async" rows, while cgroup v2
+// only reports lowercase "read"/"write", so ops are matched case-insensitively and
+// everything else is ignored. On cgroup v2 the daemon only fills the byte
+// counters and leaves IoServicedRecursive empty, so op counts stay 0 there.
+func calculateBlkio(stats container.BlkioStats) blkioTotals {
+	var totals blkioTotals
+	for _, entry := range stats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			totals.readBytes += entry.Value
+		case "write":
+			totals.writeBytes += entry.Value
+		}
+	}
+	for _, entry := range stats.IoServicedRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			totals.readOps += entry.Value
+		case "write":
+			totals.writeOps += entry.Value
+		}
+	}
+	return totals
 }
 
 func firstName(names []string) string {
@@ -329,23 +756,40 @@ func firstName(names []string) string {
 	return "unknown"
 }
 
-func calculateCPUPercent(stats docker.StatsJSON) float64 {
-	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
-	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
-	if systemDelta <= 0 || cpuDelta <= 0 {
-		return 0
-	}
+type cpuUsage struct {
+	// pct matches `docker stats`: 100 per fully used core, so it can exceed 100.
+	pct float64
+	// hostPct is the share of total host capacity, always within 0-100.
+	hostPct    float64
+	cores      float64
+	onlineCPUs uint32
+}
 
-	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+func calculateCPU(stats docker.StatsJSON) cpuUsage {
+	onlineCPUs := stats.CPUStats.OnlineCPUs
 	if onlineCPUs == 0 {
-		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+		onlineCPUs = uint32(len(stats.CPUStats.CPUUsage.PercpuUsage))
 		if onlineCPUs == 0 {
 			onlineCPUs = 1
 		}
 	}
+	usage := cpuUsage{onlineCPUs: onlineCPUs}
+
+	// The first frame of a stream has no previous reading, and counters can go
+	// backwards when a container restarts; both would produce nonsense deltas.
+	if stats.PreCPUStats.SystemUsage == 0 ||
+		stats.CPUStats.CPUUsage.TotalUsage <= stats.PreCPUStats.CPUUsage.TotalUsage ||
+		stats.CPUStats.SystemUsage <= stats.PreCPUStats.SystemUsage {
+		return usage
+	}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
 
-	cpuPercent := (cpuDelta / systemDelta) * onlineCPUs * 100
-	return clamp(cpuPercent, 0, 100)
+	share := cpuDelta / systemDelta
+	usage.cores = share * float64(onlineCPUs)
+	usage.pct = usage.cores * 100
+	usage.hostPct = clamp(share*100, 0, 100)
+	return usage
 }
 
 func clamp(value, min, max float64) float64 {
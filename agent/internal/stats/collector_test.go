@@ -1,11 +1,17 @@
 package stats
 
 import (
+	"context"
+	"io"
+	"log/slog"
 	"testing"
 	"time"
 
 	docker "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
 )
 
 func TestConvertStats(t *testing.T) {
@@ -73,32 +79,74 @@ func TestConvertStats(t *testing.T) {
 	}
 }
 
-func TestCalculateCPUPercentClamp(t *testing.T) {
+func TestCalculateCPUMultiCore(t *testing.T) {
+	// Four of eight cores busy for the whole interval.
 	stats := docker.StatsJSON{
 		Stats: container.Stats{
 			CPUStats: container.CPUStats{
 				CPUUsage: container.CPUUsage{
-					TotalUsage:  500,
-					PercpuUsage: []uint64{250, 250},
+					TotalUsage: 5_000_000_000,
 				},
-				SystemUsage: 1000,
-				OnlineCPUs:  2,
+				SystemUsage: 10_000_000_000,
+				OnlineCPUs:  8,
 			},
 			PreCPUStats: container.CPUStats{
 				CPUUsage: container.CPUUsage{
-					TotalUsage: 100,
+					TotalUsage: 1_000_000_000,
 				},
-				SystemUsage: 200,
+				SystemUsage: 2_000_000_000,
 			},
 		},
 	}
 
-	value := calculateCPUPercent(stats)
-	if value == 0 {
-		t.Fatalf("expected non-zero CPU percent")
+	usage := calculateCPU(stats)
+	if usage.pct != 400 {
+		t.Fatalf("expected docker-style 400%%, got %f", usage.pct)
 	}
-	if value > 100 {
-		t.Fatalf("expected clamp <= 100, got %f", value)
+	if usage.hostPct != 50 {
+		t.Fatalf("expected host share of 50%%, got %f", usage.hostPct)
+	}
+	if usage.cores != 4 {
+		t.Fatalf("expected 4 cores in use, got %f", usage.cores)
+	}
+	if usage.onlineCPUs != 8 {
+		t.Fatalf("expected 8 online CPUs, got %d", usage.onlineCPUs)
+	}
+}
+
+func TestCalculateCPUCounterReset(t *testing.T) {
+	stats := docker.StatsJSON{
+		Stats: container.Stats{
+			CPUStats: container.CPUStats{
+				CPUUsage:    container.CPUUsage{TotalUsage: 100},
+				SystemUsage: 1000,
+				OnlineCPUs:  2,
+			},
+			PreCPUStats: container.CPUStats{
+				CPUUsage:    container.CPUUsage{TotalUsage: 5000},
+				SystemUsage: 500,
+			},
+		},
+	}
+
+	if usage := calculateCPU(stats); usage.pct != 0 || usage.cores != 0 {
+		t.Fatalf("expected zero usage after counter reset, got %+v", usage)
+	}
+}
+
+func TestCalculateCPUFirstStreamFrame(t *testing.T) {
+	stats := docker.StatsJSON{
+		Stats: container.Stats{
+			CPUStats: container.CPUStats{
+				CPUUsage:    container.CPUUsage{TotalUsage: 900_000_000},
+				SystemUsage: 1_000_000_000,
+				OnlineCPUs:  4,
+			},
+		},
+	}
+
+	if usage := calculateCPU(stats); usage.pct != 0 {
+		t.Fatalf("expected zero usage without a previous reading, got %f", usage.pct)
 	}
 }
 
@@ -112,3 +160,464 @@ func TestFirstNameFallback(t *testing.T) {
 		t.Fatalf("expected unknown fallback, got %s", got)
 	}
 }
+
+func TestCalculateBlkioCgroupV1(t *testing.T) {
+	blkio := container.BlkioStats{
+		IoServiceBytesRecursive: []container.BlkioStatEntry{
+			{Major: 8, Minor: 0, Op: "Read", Value: 4096},
+			{Major: 8, Minor: 0, Op: "Write", Value: 8192},
+			{Major: 8, Minor: 0, Op: "Sync", Value: 12288},
+			{Major: 8, Minor: 0, Op: "Total", Value: 12288},
+			{Major: 8, Minor: 16, Op: "Read", Value: 1024},
+		},
+		IoServicedRecursive: []container.BlkioStatEntry{
+			{Major: 8, Minor: 0, Op: "Read", Value: 3},
+			{Major: 8, Minor: 0, Op: "Write", Value: 5},
+			{Major: 8, Minor: 0, Op: "Total", Value: 8},
+		},
+	}
+
+	got := calculateBlkio(blkio)
+	want := blkioTotals{readBytes: 5120, writeBytes: 8192, readOps: 3, writeOps: 5}
+	if got != want {
+		t.Fatalf("unexpected blkio totals: got %+v, want %+v", got, want)
+	}
+}
+
+func TestCalculateBlkioCgroupV2(t *testing.T) {
+	blkio := container.BlkioStats{
+		IoServiceBytesRecursive: []container.BlkioStatEntry{
+			{Major: 259, Minor: 0, Op: "read", Value: 2048},
+			{Major: 259, Minor: 0, Op: "write", Value: 4096},
+		},
+		IoServicedRecursive: []container.BlkioStatEntry{
+			{Major: 259, Minor: 0, Op: "read", Value: 2},
+			{Major: 259, Minor: 0, Op: "write", Value: 4},
+		},
+	}
+
+	got := calculateBlkio(blkio)
+	want := blkioTotals{readBytes: 2048, writeBytes: 4096, readOps: 2, writeOps: 4}
+	if got != want {
+		t.Fatalf("unexpected blkio totals: got %+v, want %+v", got, want)
+	}
+}
+
+func TestNetRateTrackerRates(t *testing.T) {
+	tracker := newNetRateTracker()
+	start := time.Unix(1700000000, 0)
+
+	rates, _ := tracker.observe("abc", start, map[string]container.NetworkStats{
+		"eth0": {RxBytes: 1000, TxBytes: 500, RxPackets: 10, TxPackets: 5},
+	})
+	if rates != (netRates{}) {
+		t.Fatalf("expected zero rates for first observation, got %+v", rates)
+	}
+
+	rates, ifaces := tracker.observe("abc", start.Add(2*time.Second), map[string]container.NetworkStats{
+		"eth0": {RxBytes: 5000, TxBytes: 1500, RxPackets: 30, TxPackets: 9, RxDropped: 2},
+		"eth1": {RxBytes: 100},
+	})
+	if rates.rxBytes != 2000 || rates.txBytes != 500 {
+		t.Fatalf("unexpected byte rates: %+v", rates)
+	}
+	if rates.rxPackets != 10 || rates.txPackets != 2 || rates.rxDropped != 1 {
+		t.Fatalf("unexpected packet rates: %+v", rates)
+	}
+	if len(ifaces) != 2 || ifaces[0].Name != "eth0" || ifaces[1].Name != "eth1" {
+		t.Fatalf("unexpected interfaces: %+v", ifaces)
+	}
+	if ifaces[0].RxBytesPerSec != 2000 {
+		t.Fatalf("unexpected eth0 rx rate: %f", ifaces[0].RxBytesPerSec)
+	}
+	if ifaces[1].RxBytesPerSec != 0 {
+		t.Fatalf("expected zero rate for new interface, got %f", ifaces[1].RxBytesPerSec)
+	}
+}
+
+func TestNetRateTrackerCounterReset(t *testing.T) {
+	tracker := newNetRateTracker()
+	start := time.Unix(1700000000, 0)
+
+	tracker.observe("abc", start, map[string]container.NetworkStats{"eth0": {RxBytes: 9000, TxBytes: 9000}})
+
+	// Container restarted: counters start over from a small value.
+	rates, _ := tracker.observe("abc", start.Add(time.Second), map[string]container.NetworkStats{"eth0": {RxBytes: 100, TxBytes: 50}})
+	if rates != (netRates{}) {
+		t.Fatalf("expected zero rates after counter reset, got %+v", rates)
+	}
+
+	rates, _ = tracker.observe("abc", start.Add(2*time.Second), map[string]container.NetworkStats{"eth0": {RxBytes: 300, TxBytes: 150}})
+	if rates.rxBytes != 200 || rates.txBytes != 100 {
+		t.Fatalf("expected rates from new baseline, got %+v", rates)
+	}
+
+	tracker.forget("abc")
+	rates, _ = tracker.observe("abc", start.Add(3*time.Second), map[string]container.NetworkStats{"eth0": {RxBytes: 900, TxBytes: 900}})
+	if rates != (netRates{}) {
+		t.Fatalf("expected zero rates after forget, got %+v", rates)
+	}
+}
+
+func TestCalculateMemoryCgroupV1(t *testing.T) {
+	mem := calculateMemory(container.MemoryStats{
+		Usage: 800,
+		Stats: map[string]uint64{
+			"total_inactive_file": 300,
+			"inactive_file":       100,
+			"total_rss":           400,
+			"total_cache":         350,
+			"total_swap":          20,
+		},
+	})
+
+	want := memoryUsage{usage: 800, workingSet: 500, rss: 400, cache: 350, swap: 20}
+	if mem != want {
+		t.Fatalf("unexpected memory usage: got %+v, want %+v", mem, want)
+	}
+}
+
+func TestCalculateMemoryCgroupV2(t *testing.T) {
+	mem := calculateMemory(container.MemoryStats{
+		Usage: 1000,
+		Stats: map[string]uint64{
+			"inactive_file": 250,
+			"anon":          600,
+			"file":          300,
+			"kernel_stack":  16,
+			"slab":          64,
+			"sock":          4,
+		},
+	})
+
+	want := memoryUsage{usage: 1000, workingSet: 750, rss: 600, cache: 300, kernel: 84}
+	if mem != want {
+		t.Fatalf("unexpected memory usage: got %+v, want %+v", mem, want)
+	}
+}
+
+func TestCalculateMemoryInactiveExceedsUsage(t *testing.T) {
+	mem := calculateMemory(container.MemoryStats{
+		Usage: 100,
+		Stats: map[string]uint64{"inactive_file": 500, "kernel": 12},
+	})
+	if mem.workingSet != 100 {
+		t.Fatalf("expected raw usage when inactive_file exceeds usage, got %d", mem.workingSet)
+	}
+	if mem.kernel != 12 {
+		t.Fatalf("expected kernel total to be used when reported, got %d", mem.kernel)
+	}
+}
+
+func TestLifecycleEvent(t *testing.T) {
+	c := &Collector{agentID: "host-a", agentLabel: "lab", labelAllowlist: labelAllowlist{"*"}}
+	occurred := time.Unix(1700000000, 0)
+
+	die, ok := c.lifecycleEvent(events.Message{
+		Action:   events.ActionDie,
+		Actor:    events.Actor{ID: "abc123", Attributes: map[string]string{"name": "web", "exitCode": "137", "tier": "frontend"}},
+		TimeNano: occurred.UnixNano(),
+	})
+	if !ok {
+		t.Fatalf("expected die event to be forwarded")
+	}
+	if die.Type != "container_event" || die.Action != "die" || die.ContainerName != "web" || die.AgentID != "host-a" {
+		t.Fatalf("unexpected die event: %+v", die)
+	}
+	if die.ExitCode == nil || *die.ExitCode != 137 {
+		t.Fatalf("expected exit code 137, got %v", die.ExitCode)
+	}
+	if len(die.Labels) != 1 || die.Labels["tier"] != "frontend" {
+		t.Fatalf("expected only container labels, got %v", die.Labels)
+	}
+	if !die.OccurredAt.Equal(occurred) {
+		t.Fatalf("unexpected occurred_at: %s", die.OccurredAt)
+	}
+
+	health, ok := c.lifecycleEvent(events.Message{
+		Action: events.Action("health_status: unhealthy"),
+		Actor:  events.Actor{ID: "abc123", Attributes: map[string]string{"name": "web"}},
+	})
+	if !ok || health.Action != "health_status" || health.HealthStatus != "unhealthy" {
+		t.Fatalf("unexpected health event: %+v", health)
+	}
+
+	rename, ok := c.lifecycleEvent(events.Message{
+		Action: events.ActionRename,
+		Actor:  events.Actor{ID: "abc123", Attributes: map[string]string{"name": "web-2", "oldName": "/web"}},
+	})
+	if !ok || rename.ContainerName != "web-2" || rename.PreviousName != "web" {
+		t.Fatalf("unexpected rename event: %+v", rename)
+	}
+
+	if _, ok := c.lifecycleEvent(events.Message{Action: events.ActionAttach, Actor: events.Actor{ID: "abc123"}}); ok {
+		t.Fatalf("expected attach event to be ignored")
+	}
+}
+
+func TestContainerMetadata(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	cont := docker.Container{
+		ID:      "abc123",
+		Image:   "postgres:16",
+		State:   "running",
+		Status:  "Up 1 hour (health: starting)",
+		Created: created.Unix(),
+		Labels: map[string]string{
+			"com.docker.compose.project": "payments",
+			"com.docker.compose.service": "db",
+			"maintainer":                 "dba-team",
+		},
+		Ports: []docker.Port{
+			{PrivatePort: 5432, Type: "tcp"},
+			{IP: "0.0.0.0", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+		},
+	}
+
+	meta := newContainerMeta(cont, labelAllowlist{"com.docker.compose.*"})
+	if meta.health != "starting" {
+		t.Fatalf("expected health parsed from status, got %q", meta.health)
+	}
+	if len(meta.labels) != 2 || meta.labels["maintainer"] != "" {
+		t.Fatalf("unexpected filtered labels: %v", meta.labels)
+	}
+
+	startedAt := time.Now().Add(-10 * time.Minute)
+	meta.applyInspect(docker.ContainerJSON{
+		ContainerJSONBase: &docker.ContainerJSONBase{
+			RestartCount: 3,
+			State: &docker.ContainerState{
+				Status:    "running",
+				StartedAt: startedAt.Format(time.RFC3339Nano),
+				Health:    &docker.Health{Status: "healthy"},
+			},
+		},
+	})
+
+	var sample types.ContainerResourceSample
+	meta.apply(&sample, time.Now())
+
+	if sample.Image != "postgres:16" || sample.State != "running" || sample.Health != "healthy" {
+		t.Fatalf("unexpected metadata: %+v", sample)
+	}
+	if sample.RestartCount != 3 {
+		t.Fatalf("unexpected restart count: %d", sample.RestartCount)
+	}
+	if sample.UptimeSecs < 590 || sample.UptimeSecs > 610 {
+		t.Fatalf("expected uptime from inspect start time, got %d", sample.UptimeSecs)
+	}
+	if len(sample.Ports) != 1 || sample.Ports[0].PublicPort != 15432 || sample.Ports[0].Protocol != "tcp" {
+		t.Fatalf("unexpected published ports: %+v", sample.Ports)
+	}
+}
+
+func TestLabelAllowlistEmpty(t *testing.T) {
+	if labels := (labelAllowlist{}).filter(map[string]string{"a": "b"}); labels != nil {
+		t.Fatalf("expected no labels without an allowlist, got %v", labels)
+	}
+}
+
+func TestSnapshotGroupRollups(t *testing.T) {
+	c := &Collector{
+		composeRollups: true,
+		groupByLabels:  []string{"team"},
+		samples: map[string]types.ContainerResourceSample{
+			"api-1": {ID: "api-1", CPUPct: 20, MemBytes: 100, NetRxBytesPerSec: 10},
+			"api-2": {ID: "api-2", CPUPct: 30, MemBytes: 200, NetRxBytesPerSec: 5},
+			"db-1":  {ID: "db-1", CPUPct: 50, MemBytes: 1000},
+			"solo":  {ID: "solo", CPUPct: 5, MemBytes: 10},
+		},
+		meta: map[string]containerMeta{
+			"api-1": {allLabels: map[string]string{composeProjectLabel: "payments", composeServiceLabel: "api", "team": "core"}},
+			"api-2": {allLabels: map[string]string{composeProjectLabel: "payments", composeServiceLabel: "api", "team": "core"}},
+			"db-1":  {allLabels: map[string]string{composeProjectLabel: "payments", composeServiceLabel: "db", "team": "data"}},
+			"solo":  {},
+		},
+	}
+
+	batch := c.snapshotLocked(time.Now())
+
+	if len(batch.ComposeProjects) != 1 {
+		t.Fatalf("expected one compose project, got %+v", batch.ComposeProjects)
+	}
+	project := batch.ComposeProjects[0]
+	if project.Name != "payments" || project.Containers != 3 || project.CPUPct != 100 || project.MemBytes != 1300 {
+		t.Fatalf("unexpected project rollup: %+v", project)
+	}
+
+	if len(batch.ComposeServices) != 2 {
+		t.Fatalf("expected two compose services, got %+v", batch.ComposeServices)
+	}
+	api := batch.ComposeServices[0]
+	if api.Name != "api" || api.Project != "payments" || api.Containers != 2 || api.NetRxBytesPerSec != 15 {
+		t.Fatalf("unexpected service rollup: %+v", api)
+	}
+
+	if len(batch.LabelGroups) != 2 || batch.LabelGroups[0].LabelKey != "team" || batch.LabelGroups[0].Name != "core" {
+		t.Fatalf("unexpected label groups: %+v", batch.LabelGroups)
+	}
+}
+
+func waitForBatch(t *testing.T, out <-chan types.ContainerStatsBatch, match func(types.ContainerStatsBatch) bool) types.ContainerStatsBatch {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case batch := <-out:
+			if match(batch) {
+				return batch
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for matching batch")
+		}
+	}
+}
+
+func hasContainer(id string) func(types.ContainerStatsBatch) bool {
+	return func(batch types.ContainerStatsBatch) bool {
+		for _, sample := range batch.Containers {
+			if sample.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func waitForEvent(t *testing.T, notify <-chan types.ContainerEventMessage) types.ContainerEventMessage {
+	t.Helper()
+	select {
+	case event := <-notify:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for event")
+		return types.ContainerEventMessage{}
+	}
+}
+
+func TestCollectorWithFakeSource(t *testing.T) {
+	source := NewFakeSource()
+	source.SetContainer(docker.Container{ID: "existing", Names: []string{"/db"}, State: "running"})
+	source.SetStats("existing", docker.StatsJSON{Stats: container.Stats{
+		MemoryStats: container.MemoryStats{Usage: 1024, Limit: 4096},
+	}})
+
+	collector := NewCollector(source, slog.New(slog.NewTextHandler(io.Discard, nil)), Options{
+		PollInterval: 10 * time.Millisecond,
+		AgentID:      "test",
+		WorkerLimit:  2,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan types.ContainerStatsBatch, 64)
+	notify := make(chan types.ContainerEventMessage, 8)
+	go collector.Collect(ctx, out, notify)
+
+	batch := waitForBatch(t, out, hasContainer("existing"))
+	if batch.Containers[0].MemBytes != 1024 || batch.Containers[0].State != "running" {
+		t.Fatalf("unexpected sample: %+v", batch.Containers[0])
+	}
+
+	source.Start(docker.Container{ID: "web", Names: []string{"/web"}})
+	waitForBatch(t, out, hasContainer("web"))
+	if event := waitForEvent(t, notify); event.Action != "start" || event.ContainerName != "web" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	source.Stop("web", 137)
+	waitForBatch(t, out, func(batch types.ContainerStatsBatch) bool { return !hasContainer("web")(batch) })
+	event := waitForEvent(t, notify)
+	if event.Action != "die" || event.ExitCode == nil || *event.ExitCode != 137 {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+}
+
+func TestCollectorCoalescesBatches(t *testing.T) {
+	source := NewFakeSource()
+	for i := 0; i < 20; i++ {
+		source.SetContainer(docker.Container{ID: string(rune('a' + i)), Names: []string{"/c"}, State: "running"})
+	}
+
+	collector := NewCollector(source, slog.New(slog.NewTextHandler(io.Discard, nil)), Options{
+		PollInterval: 20 * time.Millisecond,
+		WorkerLimit:  20,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	out := make(chan types.ContainerStatsBatch, 1024)
+	collector.Collect(ctx, out, nil)
+	close(out)
+
+	batches := 0
+	var last types.ContainerStatsBatch
+	for batch := range out {
+		if batch.Sequence <= last.Sequence {
+			t.Fatalf("sequence went from %d to %d", last.Sequence, batch.Sequence)
+		}
+		last = batch
+		batches++
+	}
+	// One per tick at most; a batch per sample would be 20 per tick.
+	if batches == 0 || batches > 300/20+1 {
+		t.Fatalf("expected one batch per tick, got %d", batches)
+	}
+	if len(last.Containers) != 20 {
+		t.Fatalf("expected all containers in the last batch, got %d", len(last.Containers))
+	}
+}
+
+func TestCollectorFlushesNewContainer(t *testing.T) {
+	source := NewFakeSource()
+	source.SetContainer(docker.Container{ID: "web", Names: []string{"/web"}, State: "running"})
+
+	// The tick never fires, so only the early flush can publish.
+	collector := NewCollector(source, slog.New(slog.NewTextHandler(io.Discard, nil)), Options{
+		PollInterval:       time.Hour,
+		FlushNewContainers: true,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan types.ContainerStatsBatch, 8)
+	go collector.Collect(ctx, out, nil)
+
+	waitForBatch(t, out, hasContainer("web"))
+}
+
+func TestCollectorDiscardsReadingOfDroppedContainer(t *testing.T) {
+	source := NewFakeSource()
+	source.SetContainer(docker.Container{ID: "web", Names: []string{"/web"}, State: "running"})
+
+	collector := NewCollector(source, slog.New(slog.NewTextHandler(io.Discard, nil)), Options{
+		PollInterval: 10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan types.ContainerStatsBatch, 64)
+	go collector.Collect(ctx, out, nil)
+	waitForBatch(t, out, hasContainer("web"))
+
+	held, release := source.HoldStats("web")
+	select {
+	case <-held:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for a stats read")
+	}
+	source.Stop("web", 0)
+	waitForBatch(t, out, func(batch types.ContainerStatsBatch) bool { return !hasContainer("web")(batch) })
+
+	// The reading completes after the container was dropped and must not bring
+	// it back.
+	release()
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case batch := <-out:
+			if hasContainer("web")(batch) {
+				t.Fatalf("dropped container reappeared: %+v", batch.Containers)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
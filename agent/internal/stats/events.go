@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// subscribedActions are the container events the collector listens for: the ones
+// that change which containers need a stats watcher, plus the lifecycle changes
+// forwarded to dashboards. Docker matches "health_status" against every
+// "health_status: <state>" action.
+var subscribedActions = []events.Action{
+	events.ActionStart,
+	events.ActionStop,
+	events.ActionRestart,
+	events.ActionDie,
+	events.ActionOOM,
+	events.ActionDestroy,
+	events.ActionPause,
+	events.ActionUnPause,
+	events.ActionRename,
+	events.ActionHealthStatus,
+}
+
+// forwardedActions are reported to dashboards as-is; die, rename and health_status
+// carry extra detail and are handled separately.
+var forwardedActions = map[events.Action]bool{
+	events.ActionStart:   true,
+	events.ActionStop:    true,
+	events.ActionRestart: true,
+	events.ActionOOM:     true,
+	events.ActionDestroy: true,
+	events.ActionPause:   true,
+	events.ActionUnPause: true,
+}
+
+func (c *Collector) handleEvent(ctx context.Context, notify chan<- types.ContainerEventMessage, msg events.Message) {
+	id := msg.Actor.ID
+	if id == "" {
+		return
+	}
+	c.log.Debug("container event",
+		slog.String("container_id", id),
+		slog.String("action", string(msg.Action)),
+	)
+
+	event, forwarded := c.lifecycleEvent(msg)
+	if forwarded {
+		c.dispatchEvent(ctx, notify, event)
+	}
+	if event.Action == string(events.ActionHealthStatus) {
+		c.setHealth(id, event.HealthStatus)
+	}
+
+	switch msg.Action {
+	case events.ActionStart, events.ActionUnPause:
+		c.refreshContainer(ctx, id, false)
+	case events.ActionRename:
+		// Watchers capture the container name, so restart them to pick up the new one.
+		c.refreshContainer(ctx, id, true)
+	case events.ActionDie, events.ActionDestroy, events.ActionPause:
+		c.dropContainer(id)
+	}
+}
+
+// refreshContainer looks the container up and ensures a watcher is running for it.
+// Lookup failures are left for the next reconcile to resolve.
+func (c *Collector) refreshContainer(ctx context.Context, id string, restart bool) {
+	containers, err := c.source.ListContainers(ctx, id)
+	if err != nil {
+		c.log.Warn("failed to look up container", slog.String("container_id", id), slog.String("error", err.Error()))
+		return
+	}
+
+	var cont *docker.Container
+	for i := range containers {
+		if containers[i].ID == id {
+			cont = &containers[i]
+			break
+		}
+	}
+	if cont == nil || isPaused(*cont) {
+		c.dropContainer(id)
+		return
+	}
+
+	c.watchersMu.Lock()
+	if restart {
+		c.stopWatcherLocked(id)
+	}
+	c.startWatcherLocked(ctx, *cont)
+	c.watchersMu.Unlock()
+}
+
+func (c *Collector) dropContainer(id string) {
+	c.watchersMu.Lock()
+	c.stopWatcherLocked(id)
+	c.watchersMu.Unlock()
+
+	c.removeSamples(func(sampleID string) bool { return sampleID == id })
+}
+
+// lifecycleEvent converts a Docker event into the dashboard message, reporting
+// false for actions that are not forwarded.
+func (c *Collector) lifecycleEvent(msg events.Message) (types.ContainerEventMessage, bool) {
+	event := types.ContainerEventMessage{
+		Type:          "container_event",
+		AgentID:       c.agentID,
+		AgentLabel:    c.agentLabel,
+		SentAt:        time.Now().UTC(),
+		ContainerID:   msg.Actor.ID,
+		ContainerName: strings.TrimPrefix(msg.Actor.Attributes["name"], "/"),
+		Action:        string(msg.Action),
+		Labels:        c.labelAllowlist.filter(eventLabels(msg.Actor.Attributes)),
+	}
+	if msg.TimeNano != 0 {
+		event.OccurredAt = time.Unix(0, msg.TimeNano).UTC()
+	} else {
+		event.OccurredAt = time.Unix(msg.Time, 0).UTC()
+	}
+
+	switch {
+	case msg.Action == events.ActionDie:
+		if code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"]); err == nil {
+			event.ExitCode = &code
+		}
+	case msg.Action == events.ActionRename:
+		event.PreviousName = strings.TrimPrefix(msg.Actor.Attributes["oldName"], "/")
+	case strings.HasPrefix(string(msg.Action), string(events.ActionHealthStatus)):
+		event.Action = string(events.ActionHealthStatus)
+		_, status, _ := strings.Cut(string(msg.Action), ":")
+		event.HealthStatus = strings.TrimSpace(status)
+	case !forwardedActions[msg.Action]:
+		return types.ContainerEventMessage{}, false
+	}
+
+	return event, true
+}
+
+// eventAttributes are the attributes the daemon adds to container events next
+// to the container's labels.
+var eventAttributes = map[string]bool{
+	"name":         true,
+	"image":        true,
+	"exitCode":     true,
+	"oldName":      true,
+	"signal":       true,
+	"execDuration": true,
+}
+
+func eventLabels(attributes map[string]string) map[string]string {
+	labels := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		if !eventAttributes[key] {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+func (c *Collector) dispatchEvent(ctx context.Context, notify chan<- types.ContainerEventMessage, event types.ContainerEventMessage) {
+	if notify == nil {
+		return
+	}
+	select {
+	case notify <- event:
+	case <-ctx.Done():
+	default:
+		c.log.Warn("dropping container event due to slow consumer",
+			slog.String("container_id", event.ContainerID),
+			slog.String("action", event.Action),
+		)
+	}
+}
+
+func isPaused(cont docker.Container) bool {
+	return cont.State == "paused"
+}
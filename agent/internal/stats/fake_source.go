@@ -0,0 +1,243 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// FakeSource is an in-memory Source for tests and demo modes. Containers, stats
+// and events are set explicitly, so a collector driven by it behaves
+// deterministically. It is safe for concurrent use.
+type FakeSource struct {
+	mu          sync.Mutex
+	containers  map[string]docker.Container
+	inspect     map[string]docker.ContainerJSON
+	stats       map[string]docker.StatsJSON
+	listErr     error
+	subscribers map[chan events.Message]chan error
+	holds       map[string]statsHold
+}
+
+// statsHold pauses a stats read; see HoldStats.
+type statsHold struct {
+	held    chan struct{}
+	release chan struct{}
+}
+
+func NewFakeSource() *FakeSource {
+	return &FakeSource{
+		containers:  make(map[string]docker.Container),
+		inspect:     make(map[string]docker.ContainerJSON),
+		stats:       make(map[string]docker.StatsJSON),
+		subscribers: make(map[chan events.Message]chan error),
+		holds:       make(map[string]statsHold),
+	}
+}
+
+// SetContainer adds or replaces a container without emitting an event; only a
+// reconcile will notice it.
+func (f *FakeSource) SetContainer(cont docker.Container) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers[cont.ID] = cont
+}
+
+// RemoveContainer forgets a container and its stats without emitting an event.
+func (f *FakeSource) RemoveContainer(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.containers, id)
+	delete(f.inspect, id)
+	delete(f.stats, id)
+}
+
+// SetInspect overrides the inspect result, which otherwise is derived from the
+// listing.
+func (f *FakeSource) SetInspect(id string, info docker.ContainerJSON) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inspect[id] = info
+}
+
+// SetStats sets the reading returned for a container until replaced.
+func (f *FakeSource) SetStats(id string, stats docker.StatsJSON) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats[id] = stats
+}
+
+// HoldStats makes the next stats read for id wait, after taking its reading,
+// until release is called. held is closed once the read is waiting, so a test
+// can act while it is in flight.
+func (f *FakeSource) HoldStats(id string) (held <-chan struct{}, release func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hold := statsHold{held: make(chan struct{}), release: make(chan struct{})}
+	f.holds[id] = hold
+	return hold.held, func() { close(hold.release) }
+}
+
+// SetListError makes listings fail with err; nil restores them.
+func (f *FakeSource) SetListError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listErr = err
+}
+
+// Start adds a running container and emits its start event.
+func (f *FakeSource) Start(cont docker.Container) {
+	if cont.State == "" {
+		cont.State = "running"
+	}
+	f.SetContainer(cont)
+	f.Emit(ContainerEvent(events.ActionStart, cont.ID, firstName(cont.Names), nil))
+}
+
+// Stop removes a container and emits a die event carrying exitCode.
+func (f *FakeSource) Stop(id string, exitCode int) {
+	f.mu.Lock()
+	name := firstName(f.containers[id].Names)
+	f.mu.Unlock()
+
+	f.RemoveContainer(id)
+	f.Emit(ContainerEvent(events.ActionDie, id, name, map[string]string{"exitCode": strconv.Itoa(exitCode)}))
+}
+
+// Emit delivers an event to every subscriber. Subscribers buffer 64 events;
+// further events are dropped for a subscriber that does not keep up.
+func (f *FakeSource) Emit(msg events.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// FailEvents ends every current subscription with err, as a daemon restart would.
+func (f *FakeSource) FailEvents(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch, errs := range f.subscribers {
+		errs <- err
+		delete(f.subscribers, ch)
+	}
+}
+
+// Subscribers reports how many event subscriptions are open, so tests can wait
+// for the collector to subscribe before emitting.
+func (f *FakeSource) Subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subscribers)
+}
+
+func (f *FakeSource) ListContainers(_ context.Context, id string) ([]docker.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	containers := make([]docker.Container, 0, len(f.containers))
+	for _, cont := range f.containers {
+		if id != "" && cont.ID != id {
+			continue
+		}
+		containers = append(containers, cont)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].ID < containers[j].ID })
+	return containers, nil
+}
+
+func (f *FakeSource) InspectContainer(_ context.Context, id string) (docker.ContainerJSON, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info, ok := f.inspect[id]; ok {
+		return info, nil
+	}
+	cont, ok := f.containers[id]
+	if !ok {
+		return docker.ContainerJSON{}, fmt.Errorf("no such container: %s", id)
+	}
+	return docker.ContainerJSON{
+		ContainerJSONBase: &docker.ContainerJSONBase{
+			ID:   cont.ID,
+			Name: "/" + firstName(cont.Names),
+			State: &docker.ContainerState{
+				Status:  cont.State,
+				Running: cont.State == "running",
+			},
+		},
+	}, nil
+}
+
+func (f *FakeSource) ContainerStats(_ context.Context, id string) (docker.StatsJSON, error) {
+	f.mu.Lock()
+	if _, ok := f.containers[id]; !ok {
+		f.mu.Unlock()
+		return docker.StatsJSON{}, fmt.Errorf("no such container: %s", id)
+	}
+	stats := f.stats[id]
+	stats.ID = id
+	if stats.Read.IsZero() {
+		stats.Read = time.Now()
+	}
+	hold, held := f.holds[id]
+	delete(f.holds, id)
+	f.mu.Unlock()
+
+	// Like a daemon that answers anyway, a held read ignores cancellation.
+	if held {
+		close(hold.held)
+		<-hold.release
+	}
+	return stats, nil
+}
+
+func (f *FakeSource) Events(ctx context.Context) (<-chan events.Message, <-chan error) {
+	msgs := make(chan events.Message, 64)
+	errs := make(chan error, 1)
+
+	f.mu.Lock()
+	f.subscribers[msgs] = errs
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[msgs]; ok {
+			delete(f.subscribers, msgs)
+			errs <- ctx.Err()
+		}
+	}()
+
+	return msgs, errs
+}
+
+// ContainerEvent builds a container event message the way the daemon reports it.
+func ContainerEvent(action events.Action, id, name string, attributes map[string]string) events.Message {
+	attrs := map[string]string{"name": strings.TrimPrefix(name, "/")}
+	for key, value := range attributes {
+		attrs[key] = value
+	}
+	now := time.Now()
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id, Attributes: attrs},
+		Scope:    "local",
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+}
@@ -0,0 +1,98 @@
+package stats
+
+import (
+	"sort"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+type groupKey struct {
+	labelKey string
+	project  string
+	name     string
+}
+
+// groupRollup accumulates per-group totals while a snapshot is being built.
+type groupRollup map[groupKey]*types.GroupSummary
+
+func (r groupRollup) add(key groupKey, sample types.ContainerResourceSample) {
+	summary, ok := r[key]
+	if !ok {
+		summary = &types.GroupSummary{
+			Name:     key.name,
+			Project:  key.project,
+			LabelKey: key.labelKey,
+		}
+		r[key] = summary
+	}
+	summary.Containers++
+	summary.CPUPct += sample.CPUPct
+	summary.CPUHostPct += sample.CPUHostPct
+	summary.MemBytes += sample.MemBytes
+	summary.NetRxBytesPerSec += sample.NetRxBytesPerSec
+	summary.NetTxBytesPerSec += sample.NetTxBytesPerSec
+}
+
+func (r groupRollup) list() []types.GroupSummary {
+	if len(r) == 0 {
+		return nil
+	}
+	groups := make([]types.GroupSummary, 0, len(r))
+	for _, summary := range r {
+		groups = append(groups, *summary)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.LabelKey != b.LabelKey {
+			return a.LabelKey < b.LabelKey
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Name < b.Name
+	})
+	return groups
+}
+
+// groupRollups builds the compose project/service rollups and one rollup per
+// configured label key. Containers without the relevant labels are left out.
+type groupRollups struct {
+	projects groupRollup
+	services groupRollup
+	labels   groupRollup
+}
+
+func newGroupRollups() groupRollups {
+	return groupRollups{
+		projects: groupRollup{},
+		services: groupRollup{},
+		labels:   groupRollup{},
+	}
+}
+
+func (g groupRollups) add(sample types.ContainerResourceSample, labels map[string]string, compose bool, groupBy []string) {
+	if compose {
+		if project := labels[composeProjectLabel]; project != "" {
+			g.projects.add(groupKey{name: project}, sample)
+			if service := labels[composeServiceLabel]; service != "" {
+				g.services.add(groupKey{project: project, name: service}, sample)
+			}
+		}
+	}
+	for _, key := range groupBy {
+		if value, ok := labels[key]; ok && value != "" {
+			g.labels.add(groupKey{labelKey: key, name: value}, sample)
+		}
+	}
+}
+
+func (g groupRollups) apply(batch *types.ContainerStatsBatch) {
+	batch.ComposeProjects = g.projects.list()
+	batch.ComposeServices = g.services.list()
+	batch.LabelGroups = g.labels.list()
+}
@@ -0,0 +1,184 @@
+package stats
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// containerMeta is the descriptive state attached to every sample. It starts from
+// the container listing, is completed by an inspect when the watcher starts, and
+// is kept current by lifecycle events.
+type containerMeta struct {
+	image        string
+	labels       map[string]string
+	state        string
+	health       string
+	restartCount int
+	startedAt    time.Time
+	ports        []types.PortMapping
+	// pid is the init process, used by the cgroup source for network counters.
+	pid int
+
+	// allLabels is the unfiltered label set, used for group rollups.
+	allLabels map[string]string
+}
+
+func newContainerMeta(cont docker.Container, allow labelAllowlist) containerMeta {
+	meta := containerMeta{
+		image:     cont.Image,
+		labels:    allow.filter(cont.Labels),
+		allLabels: cont.Labels,
+		state:     cont.State,
+		health:    healthFromStatus(cont.Status),
+		ports:     publishedPorts(cont.Ports),
+	}
+	if cont.Created > 0 {
+		meta.startedAt = time.Unix(cont.Created, 0)
+	}
+	return meta
+}
+
+func (m *containerMeta) applyInspect(info docker.ContainerJSON) {
+	if info.ContainerJSONBase == nil {
+		return
+	}
+	m.restartCount = info.RestartCount
+	if info.State == nil {
+		return
+	}
+	m.pid = info.State.Pid
+	if info.State.Status != "" {
+		m.state = info.State.Status
+	}
+	if info.State.Health != nil {
+		m.health = info.State.Health.Status
+	}
+	if startedAt, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil && !startedAt.IsZero() {
+		m.startedAt = startedAt
+	}
+}
+
+func (m containerMeta) apply(sample *types.ContainerResourceSample, now time.Time) {
+	sample.Image = m.image
+	sample.Labels = m.labels
+	sample.State = m.state
+	sample.Health = m.health
+	sample.RestartCount = m.restartCount
+	sample.Ports = m.ports
+	if !m.startedAt.IsZero() && now.After(m.startedAt) {
+		sample.UptimeSecs = uint64(now.Sub(m.startedAt).Seconds())
+	}
+}
+
+// inspectContainer fills in what the listing lacks: restart count, health and the
+// real start time. Failures are logged and the listing data is kept.
+func (c *Collector) inspectContainer(ctx context.Context, id string) {
+	requestCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	info, err := c.source.InspectContainer(requestCtx, id)
+	if err != nil {
+		c.log.Debug("failed to inspect container",
+			slog.String("container_id", id),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if meta, ok := c.meta[id]; ok {
+		meta.applyInspect(info)
+		c.meta[id] = meta
+	}
+}
+
+func (c *Collector) setHealth(id, health string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if meta, ok := c.meta[id]; ok {
+		meta.health = health
+		c.meta[id] = meta
+	}
+}
+
+// healthFromStatus extracts the health state from `docker ps` status strings such
+// as "Up 3 minutes (healthy)" or "Up 5 seconds (health: starting)".
+func healthFromStatus(status string) string {
+	open := strings.LastIndex(status, "(")
+	if open < 0 || !strings.HasSuffix(status, ")") {
+		return ""
+	}
+	inner := status[open+1 : len(status)-1]
+	inner = strings.TrimPrefix(inner, "health: ")
+	switch inner {
+	case "healthy", "unhealthy", "starting":
+		return inner
+	}
+	return ""
+}
+
+func publishedPorts(ports []docker.Port) []types.PortMapping {
+	var mappings []types.PortMapping
+	for _, port := range ports {
+		if port.PublicPort == 0 {
+			continue
+		}
+		mappings = append(mappings, types.PortMapping{
+			IP:          port.IP,
+			PrivatePort: port.PrivatePort,
+			PublicPort:  port.PublicPort,
+			Protocol:    port.Type,
+		})
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		if mappings[i].PublicPort != mappings[j].PublicPort {
+			return mappings[i].PublicPort < mappings[j].PublicPort
+		}
+		return mappings[i].IP < mappings[j].IP
+	})
+	return mappings
+}
+
+// labelAllowlist selects which container labels are copied into samples. Entries
+// are exact keys, or prefixes when they end in "*".
+type labelAllowlist []string
+
+func (a labelAllowlist) allows(key string) bool {
+	for _, pattern := range a {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+			continue
+		}
+		if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (a labelAllowlist) filter(labels map[string]string) map[string]string {
+	if len(a) == 0 || len(labels) == 0 {
+		return nil
+	}
+	var selected map[string]string
+	for key, value := range labels {
+		if !a.allows(key) {
+			continue
+		}
+		if selected == nil {
+			selected = make(map[string]string)
+		}
+		selected[key] = value
+	}
+	return selected
+}
@@ -0,0 +1,176 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+type netCounters struct {
+	rxBytes   uint64
+	txBytes   uint64
+	rxPackets uint64
+	txPackets uint64
+	rxErrors  uint64
+	txErrors  uint64
+	rxDropped uint64
+	txDropped uint64
+}
+
+type netRates struct {
+	rxBytes   float64
+	txBytes   float64
+	rxPackets float64
+	txPackets float64
+	rxErrors  float64
+	txErrors  float64
+	rxDropped float64
+	txDropped float64
+}
+
+type netObservation struct {
+	at     time.Time
+	ifaces map[string]netCounters
+}
+
+// netRateTracker turns Docker's cumulative interface counters into per-second
+// rates by remembering the previous observation for every container. It is not
+// safe for concurrent use; the collector guards it with its own mutex.
+type netRateTracker struct {
+	previous map[string]netObservation
+}
+
+func newNetRateTracker() *netRateTracker {
+	return &netRateTracker{previous: make(map[string]netObservation)}
+}
+
+// observe records the counters for a container and returns the rates since the
+// previous observation. Rates are computed per interface and summed, so an
+// interface seen for the first time, or one whose counters went backwards (the
+// container restarted and its interfaces were recreated), only establishes a new
+// baseline and contributes zero.
+func (t *netRateTracker) observe(id string, at time.Time, networks map[string]container.NetworkStats) (netRates, []types.NetworkInterfaceSample) {
+	current := netObservation{
+		at:     at,
+		ifaces: make(map[string]netCounters, len(networks)),
+	}
+	for name, nw := range networks {
+		counters := netCounters{
+			rxBytes:   nw.RxBytes,
+			txBytes:   nw.TxBytes,
+			rxPackets: nw.RxPackets,
+			txPackets: nw.TxPackets,
+			rxErrors:  nw.RxErrors,
+			txErrors:  nw.TxErrors,
+			rxDropped: nw.RxDropped,
+			txDropped: nw.TxDropped,
+		}
+		current.ifaces[name] = counters
+	}
+
+	prev, ok := t.previous[id]
+	if ok && !at.After(prev.at) {
+		// Duplicate or out-of-order read; keep the older baseline.
+		return netRates{}, zeroInterfaceRates(current.ifaces)
+	}
+	t.previous[id] = current
+	if !ok {
+		return netRates{}, zeroInterfaceRates(current.ifaces)
+	}
+
+	elapsed := at.Sub(prev.at).Seconds()
+	var total netRates
+	ifaces := make([]types.NetworkInterfaceSample, 0, len(current.ifaces))
+	for name, counters := range current.ifaces {
+		var rates netRates
+		if before, seen := prev.ifaces[name]; seen {
+			rates = counters.ratesSince(before, elapsed)
+		}
+		total = total.add(rates)
+		ifaces = append(ifaces, rates.interfaceSample(name))
+	}
+	sortInterfaces(ifaces)
+
+	return total, ifaces
+}
+
+func (t *netRateTracker) forget(id string) {
+	delete(t.previous, id)
+}
+
+func (r netRates) add(other netRates) netRates {
+	return netRates{
+		rxBytes:   r.rxBytes + other.rxBytes,
+		txBytes:   r.txBytes + other.txBytes,
+		rxPackets: r.rxPackets + other.rxPackets,
+		txPackets: r.txPackets + other.txPackets,
+		rxErrors:  r.rxErrors + other.rxErrors,
+		txErrors:  r.txErrors + other.txErrors,
+		rxDropped: r.rxDropped + other.rxDropped,
+		txDropped: r.txDropped + other.txDropped,
+	}
+}
+
+// ratesSince yields zero rates when any counter decreased, which indicates a reset.
+func (n netCounters) ratesSince(prev netCounters, elapsed float64) netRates {
+	if elapsed <= 0 {
+		return netRates{}
+	}
+	if n.rxBytes < prev.rxBytes || n.txBytes < prev.txBytes ||
+		n.rxPackets < prev.rxPackets || n.txPackets < prev.txPackets ||
+		n.rxErrors < prev.rxErrors || n.txErrors < prev.txErrors ||
+		n.rxDropped < prev.rxDropped || n.txDropped < prev.txDropped {
+		return netRates{}
+	}
+	return netRates{
+		rxBytes:   float64(n.rxBytes-prev.rxBytes) / elapsed,
+		txBytes:   float64(n.txBytes-prev.txBytes) / elapsed,
+		rxPackets: float64(n.rxPackets-prev.rxPackets) / elapsed,
+		txPackets: float64(n.txPackets-prev.txPackets) / elapsed,
+		rxErrors:  float64(n.rxErrors-prev.rxErrors) / elapsed,
+		txErrors:  float64(n.txErrors-prev.txErrors) / elapsed,
+		rxDropped: float64(n.rxDropped-prev.rxDropped) / elapsed,
+		txDropped: float64(n.txDropped-prev.txDropped) / elapsed,
+	}
+}
+
+func (r netRates) interfaceSample(name string) types.NetworkInterfaceSample {
+	return types.NetworkInterfaceSample{
+		Name:            name,
+		RxBytesPerSec:   r.rxBytes,
+		TxBytesPerSec:   r.txBytes,
+		RxPacketsPerSec: r.rxPackets,
+		TxPacketsPerSec: r.txPackets,
+		RxErrorsPerSec:  r.rxErrors,
+		TxErrorsPerSec:  r.txErrors,
+		RxDroppedPerSec: r.rxDropped,
+		TxDroppedPerSec: r.txDropped,
+	}
+}
+
+func (r netRates) apply(sample *types.ContainerResourceSample) {
+	sample.NetRxBytesPerSec = r.rxBytes
+	sample.NetTxBytesPerSec = r.txBytes
+	sample.NetRxPacketsPerSec = r.rxPackets
+	sample.NetTxPacketsPerSec = r.txPackets
+	sample.NetRxErrorsPerSec = r.rxErrors
+	sample.NetTxErrorsPerSec = r.txErrors
+	sample.NetRxDroppedPerSec = r.rxDropped
+	sample.NetTxDroppedPerSec = r.txDropped
+}
+
+func zeroInterfaceRates(ifaces map[string]netCounters) []types.NetworkInterfaceSample {
+	samples := make([]types.NetworkInterfaceSample, 0, len(ifaces))
+	for name := range ifaces {
+		samples = append(samples, types.NetworkInterfaceSample{Name: name})
+	}
+	sortInterfaces(samples)
+	return samples
+}
+
+func sortInterfaces(samples []types.NetworkInterfaceSample) {
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+}
@@ -0,0 +1,120 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Source is where the collector discovers containers, reads their stats and
+// learns about lifecycle changes. The Docker Engine API types are used as the
+// common vocabulary so every source feeds the same conversion code.
+type Source interface {
+	// ListContainers returns running containers, or only the container with the
+	// given ID when id is not empty.
+	ListContainers(ctx context.Context, id string) ([]docker.Container, error)
+	InspectContainer(ctx context.Context, id string) (docker.ContainerJSON, error)
+	// ContainerStats returns a single stats reading.
+	ContainerStats(ctx context.Context, id string) (docker.StatsJSON, error)
+	// Events delivers container lifecycle events until ctx is done or the error
+	// channel yields.
+	Events(ctx context.Context) (<-chan events.Message, <-chan error)
+}
+
+// StatsStreamer is implemented by sources that can push stats continuously. Stream
+// mode falls back to polling for sources without it.
+type StatsStreamer interface {
+	StreamStats(ctx context.Context, id string) (StatsStream, error)
+}
+
+// StatsStream yields readings until it fails; Next returns io.EOF when the source
+// ends the stream.
+type StatsStream interface {
+	Next() (docker.StatsJSON, error)
+	Close() error
+}
+
+type dockerSource struct {
+	client *client.Client
+}
+
+// NewDockerSource returns the default source, backed by the Docker Engine API.
+func NewDockerSource(cli *client.Client) Source {
+	return &dockerSource{client: cli}
+}
+
+func (s *dockerSource) ListContainers(ctx context.Context, id string) ([]docker.Container, error) {
+	args := filters.NewArgs()
+	if id != "" {
+		args.Add("id", id)
+	}
+	return s.client.ContainerList(ctx, container.ListOptions{Filters: args})
+}
+
+func (s *dockerSource) InspectContainer(ctx context.Context, id string) (docker.ContainerJSON, error) {
+	return s.client.ContainerInspect(ctx, id)
+}
+
+func (s *dockerSource) ContainerStats(ctx context.Context, id string) (docker.StatsJSON, error) {
+	resp, err := s.client.ContainerStats(ctx, id, false)
+	if err != nil {
+		return docker.StatsJSON{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.Body == nil {
+		return docker.StatsJSON{}, errors.New("nil stats body")
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return docker.StatsJSON{}, err
+	}
+
+	var stats docker.StatsJSON
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return docker.StatsJSON{}, err
+	}
+
+	return stats, nil
+}
+
+func (s *dockerSource) StreamStats(ctx context.Context, id string) (StatsStream, error) {
+	resp, err := s.client.ContainerStats(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerStatsStream{body: resp.Body, decoder: json.NewDecoder(resp.Body)}, nil
+}
+
+func (s *dockerSource) Events(ctx context.Context) (<-chan events.Message, <-chan error) {
+	args := filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)))
+	for _, action := range subscribedActions {
+		args.Add("event", string(action))
+	}
+	return s.client.Events(ctx, events.ListOptions{Filters: args})
+}
+
+type dockerStatsStream struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+}
+
+func (s *dockerStatsStream) Next() (docker.StatsJSON, error) {
+	var stats docker.StatsJSON
+	if err := s.decoder.Decode(&stats); err != nil {
+		return docker.StatsJSON{}, err
+	}
+	return stats, nil
+}
+
+func (s *dockerStatsStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// countingWriter hands gorilla a connection that counts what is written to it
+// once hijacked. With compression that is what actually crosses the network,
+// frame headers and the handshake included.
+type countingWriter struct {
+	http.ResponseWriter
+	written *atomic.Uint64
+}
+
+func (w countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return countingConn{Conn: conn, written: w.written}, rw, nil
+}
+
+type countingConn struct {
+	net.Conn
+	written *atomic.Uint64
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(uint64(n))
+	return n, err
+}
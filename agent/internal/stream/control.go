@@ -0,0 +1,152 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// maxControlMessage bounds what a client may send; filters are the largest.
+const maxControlMessage = 4096
+
+// controlMessage is a request sent by a dashboard over its WebSocket. Every
+// request is answered with an ack or error reply carrying the same ID.
+type controlMessage struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id,omitempty"`
+	Mode     string   `json:"mode,omitempty"`
+	Since    *uint64  `json:"since,omitempty"`
+	Messages []string `json:"messages,omitempty"`
+	Names    []string `json:"names,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+	Fields   []string `json:"fields,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+
+	client *client
+	// err is set when the message could not be decoded.
+	err error
+}
+
+func (h *Hub) handleControl(msg controlMessage) {
+	c := msg.client
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	if msg.err != nil {
+		h.reply(c, msg, fmt.Errorf("invalid message: %w", msg.err))
+		return
+	}
+
+	then, err := h.applyControl(c, msg)
+	h.reply(c, msg, err)
+	if err != nil {
+		h.log.Debug("rejected client message", slog.String("type", msg.Type), slog.String("error", err.Error()))
+		return
+	}
+	// Anything the request triggers goes out after its ack.
+	if then != nil {
+		then()
+	}
+}
+
+// applyControl updates the client for msg and returns what to send once the
+// request has been acknowledged.
+func (h *Hub) applyControl(c *client, msg controlMessage) (func(), error) {
+	keyframe := func() { h.sendKeyframe(c) }
+
+	switch msg.Type {
+	case "subscribe", "unsubscribe":
+		if len(msg.Messages) == 0 {
+			return nil, errors.New("messages is required")
+		}
+		kinds, err := parseKinds(msg.Messages)
+		if err != nil {
+			return nil, err
+		}
+		current := make(map[string]bool, len(messageKinds))
+		for _, kind := range messageKinds {
+			current[kind] = c.sub.wants(kind)
+		}
+		for kind := range kinds {
+			current[kind] = msg.Type == "subscribe"
+		}
+		wasStats := c.sub.wants(kindStats)
+		c.sub.kinds = current
+		if !wasStats && c.sub.wants(kindStats) {
+			return keyframe, nil
+		}
+		return nil, nil
+	case "set_filter":
+		names, err := parseNames(msg.Names)
+		if err != nil {
+			return nil, err
+		}
+		labels, err := parseLabels(msg.Labels)
+		if err != nil {
+			return nil, err
+		}
+		c.sub.names, c.sub.labels = names, labels
+		return keyframe, nil
+	case "set_fields":
+		fields, err := parseFields(msg.Fields)
+		if err != nil {
+			return nil, err
+		}
+		c.sub.fields = fields
+		return keyframe, nil
+	case "set_rate":
+		interval, err := parseInterval(msg.Interval)
+		if err != nil {
+			return nil, err
+		}
+		custom := c.sub.custom()
+		c.sub.interval = interval
+		if custom != c.sub.custom() {
+			// Moving between shared and per-client deltas needs a fresh base.
+			return keyframe, nil
+		}
+		return nil, nil
+	case "set_stats_mode":
+		mode, ok := parseStatsMode(msg.Mode)
+		if !ok {
+			return nil, fmt.Errorf("unknown stats mode %q", msg.Mode)
+		}
+		if mode == c.mode {
+			return nil, nil
+		}
+		c.mode = mode
+		c.view = nil
+		if mode == statsModeDelta {
+			return keyframe, nil
+		}
+		return nil, nil
+	case "request_keyframe":
+		return keyframe, nil
+	case "resume":
+		if msg.Since == nil {
+			return nil, errors.New("since is required")
+		}
+		since := *msg.Since
+		return func() { h.resume(c, since) }, nil
+	case "":
+		return nil, errors.New("type is required")
+	default:
+		return nil, fmt.Errorf("unknown message type %q", msg.Type)
+	}
+}
+
+func (h *Hub) reply(c *client, msg controlMessage, err error) {
+	reply := types.ControlReply{Type: "ack", ID: msg.ID, Request: msg.Type}
+	if err != nil {
+		reply.Type = "error"
+		reply.Error = err.Error()
+	}
+	payload, marshalErr := c.codec.marshal(reply)
+	if marshalErr != nil {
+		h.log.Warn("failed to encode control reply", slog.String("encoding", c.codec.name), slog.String("error", marshalErr.Error()))
+		return
+	}
+	h.send(c, payload)
+}
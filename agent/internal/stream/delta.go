@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// deltaView is the container state a delta client holds after applying every
+// message so far. Deltas are computed against it rather than against the previous
+// batch, so changes below epsilon cannot pile up unseen across ticks.
+type deltaView struct {
+	sequence uint64
+	samples  map[string]types.ContainerResourceSample
+}
+
+func newDeltaView(batch types.ContainerStatsBatch) *deltaView {
+	view := &deltaView{
+		sequence: batch.Sequence,
+		samples:  make(map[string]types.ContainerResourceSample, len(batch.Containers)),
+	}
+	for _, sample := range batch.Containers {
+		view.samples[sample.ID] = sample
+	}
+	return view
+}
+
+// advance returns the delta that takes the view to batch and applies it.
+// Containers are included when new or when any value moved by more than epsilon,
+// relative to its previous value; everything else in the batch is always sent.
+func (v *deltaView) advance(batch types.ContainerStatsBatch, epsilon float64) types.ContainerStatsDelta {
+	delta := types.ContainerStatsDelta{
+		Type:            "container_stats_delta",
+		AgentID:         batch.AgentID,
+		AgentLabel:      batch.AgentLabel,
+		SentAt:          batch.SentAt,
+		Sequence:        batch.Sequence,
+		BaseSequence:    v.sequence,
+		Containers:      []types.ContainerResourceSample{},
+		AgentMetrics:    batch.AgentMetrics,
+		ComposeProjects: batch.ComposeProjects,
+		ComposeServices: batch.ComposeServices,
+		LabelGroups:     batch.LabelGroups,
+		Host:            batch.Host,
+	}
+
+	present := make(map[string]bool, len(batch.Containers))
+	for _, sample := range batch.Containers {
+		present[sample.ID] = true
+		if prev, ok := v.samples[sample.ID]; ok && !sampleChanged(prev, sample, epsilon) {
+			continue
+		}
+		delta.Containers = append(delta.Containers, sample)
+		v.samples[sample.ID] = sample
+	}
+	for id := range v.samples {
+		if present[id] {
+			continue
+		}
+		delta.Removed = append(delta.Removed, id)
+		delete(v.samples, id)
+	}
+	sort.Strings(delta.Removed)
+
+	v.sequence = batch.Sequence
+	return delta
+}
+
+// sampleChanged compares every field: numbers with a relative tolerance, anything
+// else (names, state, labels, ports) exactly.
+func sampleChanged(prev, next types.ContainerResourceSample, epsilon float64) bool {
+	a := reflect.ValueOf(prev)
+	b := reflect.ValueOf(next)
+	for i := 0; i < a.NumField(); i++ {
+		fa, fb := a.Field(i), b.Field(i)
+		switch fa.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if differs(fa.Float(), fb.Float(), epsilon) {
+				return true
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if differs(float64(fa.Uint()), float64(fb.Uint()), epsilon) {
+				return true
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if fa.Int() != fb.Int() {
+				return true
+			}
+		default:
+			if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func differs(a, b, epsilon float64) bool {
+	if a == b {
+		return false
+	}
+	return math.Abs(a-b) > epsilon*math.Max(math.Abs(a), math.Abs(b))
+}
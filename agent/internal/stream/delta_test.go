@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func testBatch(sequence uint64, samples ...types.ContainerResourceSample) types.ContainerStatsBatch {
+	return types.ContainerStatsBatch{
+		Type:       "container_stats_batch",
+		AgentID:    "agent",
+		Sequence:   sequence,
+		Containers: samples,
+	}
+}
+
+func TestDeltaViewAdvance(t *testing.T) {
+	view := newDeltaView(testBatch(1,
+		types.ContainerResourceSample{ID: "a", CPUPct: 10, MemBytes: 1000},
+		types.ContainerResourceSample{ID: "b", CPUPct: 20},
+		types.ContainerResourceSample{ID: "c", State: "running"},
+	))
+
+	delta := view.advance(testBatch(2,
+		types.ContainerResourceSample{ID: "a", CPUPct: 10.05, MemBytes: 1005},
+		types.ContainerResourceSample{ID: "b", CPUPct: 25},
+		types.ContainerResourceSample{ID: "d", CPUPct: 1},
+	), 0.01)
+
+	if delta.Type != "container_stats_delta" || delta.Sequence != 2 || delta.BaseSequence != 1 {
+		t.Fatalf("unexpected delta header: %+v", delta)
+	}
+	if len(delta.Containers) != 2 || delta.Containers[0].ID != "b" || delta.Containers[1].ID != "d" {
+		t.Fatalf("unexpected changed containers: %+v", delta.Containers)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0] != "c" {
+		t.Fatalf("unexpected removed containers: %v", delta.Removed)
+	}
+}
+
+func TestDeltaViewAccumulatesSmallChanges(t *testing.T) {
+	view := newDeltaView(testBatch(1, types.ContainerResourceSample{ID: "a", CPUPct: 100}))
+
+	// Each step is under 1% of the previous reading, but the view keeps the
+	// value the client last saw, so the drift is eventually reported.
+	var reported bool
+	for i, cpu := range []float64{100.6, 101.2, 101.8} {
+		delta := view.advance(testBatch(uint64(i+2), types.ContainerResourceSample{ID: "a", CPUPct: cpu}), 0.01)
+		if len(delta.Containers) > 0 {
+			reported = true
+			if delta.Containers[0].CPUPct != cpu {
+				t.Fatalf("delta carried %f, want %f", delta.Containers[0].CPUPct, cpu)
+			}
+		}
+	}
+	if !reported {
+		t.Fatalf("expected accumulated drift to be sent")
+	}
+}
+
+func TestSampleChangedComparesNonNumericFields(t *testing.T) {
+	prev := types.ContainerResourceSample{ID: "a", State: "running", Labels: map[string]string{"tier": "web"}}
+	next := prev
+	if sampleChanged(prev, next, 0.01) {
+		t.Fatalf("identical samples reported as changed")
+	}
+	next.Health = "unhealthy"
+	if !sampleChanged(prev, next, 0.01) {
+		t.Fatalf("health change not detected")
+	}
+	next = prev
+	next.RestartCount = 1
+	if !sampleChanged(prev, next, 0.01) {
+		t.Fatalf("restart count change not detected")
+	}
+}
@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec is a message encoding a client can negotiate as a WebSocket
+// subprotocol. Binary encodings reuse the JSON field names.
+type codec struct {
+	name      string
+	binary    bool
+	marshal   func(any) ([]byte, error)
+	unmarshal func([]byte, any) error
+}
+
+var (
+	jsonCodec    = &codec{name: "json", marshal: json.Marshal, unmarshal: json.Unmarshal}
+	msgpackCodec = &codec{name: "msgpack", binary: true, marshal: marshalMsgpack, unmarshal: unmarshalMsgpack}
+	cborCodec    = &codec{name: "cbor", binary: true, marshal: cborEncoding.Marshal, unmarshal: cbor.Unmarshal}
+)
+
+var codecs = []*codec{jsonCodec, msgpackCodec, cborCodec}
+
+// cborEncoding writes times as RFC 3339 strings, like JSON, rather than as
+// whole seconds.
+var cborEncoding = func() cbor.EncMode {
+	mode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+func marshalMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalMsgpack(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (c *codec) messageType() int {
+	if c.binary {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+// negotiateCodec picks the first subprotocol the client offered that names an
+// encoding. Clients that offer none get JSON and no subprotocol.
+func negotiateCodec(r *http.Request) (*codec, bool) {
+	for _, protocol := range websocket.Subprotocols(r) {
+		for _, c := range codecs {
+			if protocol == c.name {
+				return c, true
+			}
+		}
+	}
+	return jsonCodec, false
+}
+
+// encoded is a message sent to many clients. It is encoded at most once per
+// codec, the first time a client using that codec needs it. Only the hub
+// goroutine may use it.
+type encoded struct {
+	value any
+	cache map[*codec][]byte
+}
+
+func newEncoded(value any) *encoded {
+	return &encoded{value: value}
+}
+
+func (e *encoded) bytes(c *codec) ([]byte, error) {
+	if payload, ok := e.cache[c]; ok {
+		return payload, nil
+	}
+	payload, err := c.marshal(e.value)
+	if err != nil {
+		return nil, err
+	}
+	if e.cache == nil {
+		e.cache = make(map[*codec][]byte, len(codecs))
+	}
+	e.cache[c] = payload
+	return payload, nil
+}
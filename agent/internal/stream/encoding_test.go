@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func TestBinaryCodecsUseJSONFieldNames(t *testing.T) {
+	sub := subscription{}
+	sub.fields, _ = parseFields([]string{"cpu_pct"})
+	batch := testBatch(7, types.ContainerResourceSample{ID: "a", Name: "web", CPUPct: 12.5})
+	batch.SentAt = time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+
+	for _, c := range []*codec{msgpackCodec, cborCodec} {
+		data, err := c.marshal(sub.batchMessage(batch))
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", c.name, err)
+		}
+		var decoded struct {
+			Type       string           `json:"type"`
+			Sequence   uint64           `json:"sequence"`
+			Containers []map[string]any `json:"containers"`
+		}
+		if err := c.unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: unmarshal: %v", c.name, err)
+		}
+		if decoded.Type != "container_stats_batch" || decoded.Sequence != 7 {
+			t.Fatalf("%s: unexpected message %+v", c.name, decoded)
+		}
+		// The projection must shadow the embedded containers.
+		if len(decoded.Containers) != 1 || len(decoded.Containers[0]) != 2 || decoded.Containers[0]["id"] != "a" {
+			t.Fatalf("%s: unexpected containers %+v", c.name, decoded.Containers)
+		}
+	}
+}
+
+// countKey counts how often key occurs in the encoded top-level map. Decoding
+// into a map would merge duplicates, so JSON and MessagePack are read entry by
+// entry and CBOR is decoded with duplicate keys rejected.
+func countKey(c *codec, data []byte, key string) (int, error) {
+	count := 0
+	switch c {
+	case jsonCodec:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if _, err := dec.Token(); err != nil {
+			return 0, err
+		}
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return 0, err
+			}
+			if name == key {
+				count++
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return 0, err
+			}
+		}
+	case msgpackCodec:
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		entries, err := dec.DecodeMapLen()
+		if err != nil {
+			return 0, err
+		}
+		for i := 0; i < entries; i++ {
+			name, err := dec.DecodeString()
+			if err != nil {
+				return 0, err
+			}
+			if name == key {
+				count++
+			}
+			if err := dec.Skip(); err != nil {
+				return 0, err
+			}
+		}
+	case cborCodec:
+		mode, err := cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}.DecMode()
+		if err != nil {
+			return 0, err
+		}
+		var decoded map[string]cbor.RawMessage
+		if err := mode.Unmarshal(data, &decoded); err != nil {
+			return 0, err
+		}
+		if _, ok := decoded[key]; ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestProjectedMessagesHaveOneContainersKey(t *testing.T) {
+	projected := subscription{}
+	projected.fields, _ = parseFields([]string{"cpu_pct"})
+	sample := types.ContainerResourceSample{ID: "a", Name: "web", CPUPct: 12.5}
+	delta := types.ContainerStatsDelta{Type: "container_stats_delta", Sequence: 2, BaseSequence: 1, Containers: []types.ContainerResourceSample{sample}}
+
+	for _, sub := range []subscription{{}, projected} {
+		messages := map[string]any{
+			"batch": sub.batchMessage(testBatch(1, sample)),
+			"delta": sub.deltaMessage(delta),
+		}
+		for kind, msg := range messages {
+			for _, c := range []*codec{jsonCodec, msgpackCodec, cborCodec} {
+				data, err := c.marshal(msg)
+				if err != nil {
+					t.Fatalf("%s %s: marshal: %v", c.name, kind, err)
+				}
+				count, err := countKey(c, data, "containers")
+				if err != nil {
+					t.Fatalf("%s %s: decode: %v", c.name, kind, err)
+				}
+				if count != 1 {
+					t.Fatalf("%s %s: expected one containers key, got %d", c.name, kind, count)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodedOncePerCodec(t *testing.T) {
+	calls := 0
+	counting := &codec{name: "counting", marshal: func(v any) ([]byte, error) {
+		calls++
+		return jsonCodec.marshal(v)
+	}}
+	msg := newEncoded(testBatch(1))
+	for i := 0; i < 3; i++ {
+		if _, err := msg.bytes(counting); err != nil {
+			t.Fatalf("bytes: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one encoding, got %d", calls)
+	}
+}
+
+func TestHubNegotiatesEncoding(t *testing.T) {
+	hub, url := startHub(t, Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour})
+	dialer := websocket.Dialer{Subprotocols: []string{"example.v2", "msgpack", "cbor"}}
+	conn, resp, err := dialer.Dial(url+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "msgpack" {
+		t.Fatalf("expected msgpack to be selected, got %q", got)
+	}
+	plain := dial(t, url+"/ws")
+	waitForClients(t, hub, 2)
+
+	readBinary := func() message {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("expected a binary message, got type %d", messageType)
+		}
+		var msg message
+		if err := msgpackCodec.unmarshal(data, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return msg
+	}
+
+	hub.PublishBatch(testBatch(1, types.ContainerResourceSample{ID: "a", CPUPct: 10}))
+	if msg := readBinary(); msg.Type != "container_stats_batch" || msg.Sequence != 1 || len(msg.Containers) != 1 {
+		t.Fatalf("msgpack client got %+v", msg)
+	}
+	if msg := readMessage(t, plain); msg.Type != "container_stats_batch" || msg.Sequence != 1 {
+		t.Fatalf("json client got %+v", msg)
+	}
+
+	request, _ := msgpackCodec.marshal(map[string]any{"type": "request_keyframe", "id": "k"})
+	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := readBinary(); msg.Type != "ack" || msg.ID != "k" {
+		t.Fatalf("expected ack, got %+v", msg)
+	}
+	if msg := readBinary(); msg.Type != "container_stats_batch" || msg.Sequence != 1 {
+		t.Fatalf("expected keyframe, got %+v", msg)
+	}
+}
@@ -2,36 +2,115 @@ package stream
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+const (
+	statsModeFull  = "full"
+	statsModeDelta = "delta"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// Options controls how stats batches are delivered to clients.
+type Options struct {
+	// DeltaEpsilon is the relative change a value must exceed for its container
+	// to be included in a delta.
+	DeltaEpsilon float64
+	// KeyframeInterval is how often delta clients receive a full batch anyway.
+	KeyframeInterval time.Duration
+	// ReplayBatches is how many recent batches, with the events between them,
+	// are kept for clients resuming from a sequence. Zero disables resuming.
+	ReplayBatches int
+	// LastBatch returns the collector's most recent batch, sent to clients as
+	// they connect. It may be nil.
+	LastBatch func() *types.ContainerStatsBatch
+	// SlowClientPolicy is PolicyCoalesce or PolicyDisconnect.
+	SlowClientPolicy string
+	// ClientQueue is how many messages may wait for a client: control replies
+	// and events, plus stats under PolicyDisconnect. A client that fills it is
+	// disconnected.
+	ClientQueue int
+	// CompressionLevel enables permessage-deflate at this flate level for
+	// clients that offer it; zero disables it. Messages shorter than
+	// CompressionThreshold bytes are sent uncompressed.
+	CompressionLevel     int
+	CompressionThreshold int
+}
+
+// Metrics counts what the hub did for slow clients since it started.
+type Metrics struct {
+	Clients             int    `json:"clients"`
+	CoalescedBatches    uint64 `json:"coalesced_batches"`
+	DisconnectedClients uint64 `json:"slow_clients_disconnected"`
+	// PayloadBytes is the size of the messages written to clients and WireBytes
+	// what was sent on their connections, after compression and with framing.
+	PayloadBytes uint64 `json:"payload_bytes"`
+	WireBytes    uint64 `json:"wire_bytes"`
 }
 
 type Hub struct {
 	log       *slog.Logger
+	opts      Options
 	clients   map[*client]struct{}
 	register  chan *client
 	remove    chan *client
-	broadcast chan []byte
+	control   chan controlMessage
+	batches   chan types.ContainerStatsBatch
+	statuses  chan types.AgentStatusMessage
+	events    chan types.ContainerEventMessage
+	done      chan struct{}
+	connected atomic.Int64
+	coalesced atomic.Uint64
+	dropped   atomic.Uint64
+	payload   atomic.Uint64
+	wire      atomic.Uint64
+	upgrader  websocket.Upgrader
+
+	// view is the state every delta client on the shared path holds. It is
+	// dropped while no such client exists, which forces a keyframe when one
+	// shows up.
+	view         *deltaView
+	lastKeyframe time.Time
+	latest       *encoded
+	latestBatch  *types.ContainerStatsBatch
+	status       *encoded
+	replay       *replayBuffer
 }
 
-func NewHub(logger *slog.Logger) *Hub {
+func NewHub(logger *slog.Logger, opts Options) *Hub {
+	if opts.ClientQueue <= 0 {
+		opts.ClientQueue = 64
+	}
 	return &Hub{
-		log:       logger,
-		clients:   map[*client]struct{}{},
-		register:  make(chan *client),
-		remove:    make(chan *client),
-		broadcast: make(chan []byte, 256),
+		log:      logger,
+		opts:     opts,
+		clients:  map[*client]struct{}{},
+		register: make(chan *client),
+		remove:   make(chan *client),
+		control:  make(chan controlMessage),
+		batches:  make(chan types.ContainerStatsBatch, 64),
+		statuses: make(chan types.AgentStatusMessage, 4),
+		events:   make(chan types.ContainerEventMessage, 64),
+		done:     make(chan struct{}),
+		replay:   newReplayBuffer(opts.ReplayBatches),
+		upgrader: websocket.Upgrader{
+			CheckOrigin:       func(r *http.Request) bool { return true },
+			EnableCompression: opts.CompressionLevel > 0,
+		},
 	}
 }
 
 func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
 	for {
 		select {
 		case <-ctx.Done():
@@ -39,45 +118,405 @@ func (h *Hub) Run(ctx context.Context) {
 			return
 		case c := <-h.register:
 			h.clients[c] = struct{}{}
-			h.log.Debug("client connected", slog.Int("clients", len(h.clients)))
+			h.connected.Store(int64(len(h.clients)))
+			h.log.Debug("client connected", slog.Int("clients", len(h.clients)), slog.String("stats_mode", c.mode))
+			if c.since != nil {
+				h.resume(c, *c.since)
+			} else {
+				h.sendSnapshot(c)
+			}
 		case c := <-h.remove:
 			h.disconnect(c)
-		case msg := <-h.broadcast:
-			h.log.Debug("broadcasting payload", slog.Int("clients", len(h.clients)), slog.Int("bytes", len(msg)))
+		case msg := <-h.control:
+			h.handleControl(msg)
+		case batch := <-h.batches:
+			h.publishBatch(batch)
+		case event := <-h.events:
+			h.publishEvent(event)
+		case status := <-h.statuses:
+			h.status = newEncoded(status)
 			for c := range h.clients {
-				select {
-				case c.send <- msg:
-				default:
-					h.log.Debug("dropping slow client")
-					h.disconnect(c)
+				if c.sub.wants(kindStatus) {
+					h.sendEncoded(c, h.status)
 				}
 			}
 		}
 	}
 }
 
-func (h *Hub) Broadcast(data []byte) {
+// Clients reports how many clients are registered.
+func (h *Hub) Clients() int {
+	return int(h.connected.Load())
+}
+
+func (h *Hub) Metrics() Metrics {
+	return Metrics{
+		Clients:             h.Clients(),
+		CoalescedBatches:    h.coalesced.Load(),
+		DisconnectedClients: h.dropped.Load(),
+		PayloadBytes:        h.payload.Load(),
+		WireBytes:           h.wire.Load(),
+	}
+}
+
+// PublishStatus sends status to every client and keeps it for clients that
+// connect later.
+func (h *Hub) PublishStatus(status types.AgentStatusMessage) {
+	select {
+	case h.statuses <- status:
+	default:
+		h.log.Warn("dropping agent status")
+	}
+}
+
+// PublishEvent sends event to every client and keeps it for replay.
+func (h *Hub) PublishEvent(event types.ContainerEventMessage) {
 	select {
-	case h.broadcast <- data:
+	case h.events <- event:
 	default:
-		h.log.Warn("dropping broadcast message", slog.Int("bytes", len(data)))
+		h.log.Warn("dropping container event", slog.String("container_id", event.ContainerID))
+	}
+}
+
+func (h *Hub) publishEvent(event types.ContainerEventMessage) {
+	if h.latestBatch != nil {
+		event.Sequence = h.latestBatch.Sequence
+	}
+	msg := newEncoded(event)
+	h.replay.addEvent(replayEvent{event: event, message: msg})
+	for c := range h.clients {
+		if c.sub.wants(kindEvents) && c.sub.matchesEvent(event) {
+			h.sendEncoded(c, msg)
+		}
+	}
+}
+
+// PublishBatch sends batch to every client, as a full batch or as a delta
+// depending on the mode each client negotiated.
+func (h *Hub) PublishBatch(batch types.ContainerStatsBatch) {
+	select {
+	case h.batches <- batch:
+	default:
+		h.log.Warn("dropping stats batch", slog.Uint64("sequence", batch.Sequence))
+	}
+}
+
+func (h *Hub) publishBatch(batch types.ContainerStatsBatch) {
+	full := newEncoded(batch)
+	h.latest = full
+	h.latestBatch = &batch
+
+	entry := replayEntry{sequence: batch.Sequence, batch: &batch, full: full}
+	if h.sharedDeltaClients() == 0 {
+		h.view = nil
+	} else if h.view == nil || time.Since(h.lastKeyframe) >= h.opts.KeyframeInterval {
+		h.view = newDeltaView(batch)
+		h.lastKeyframe = time.Now()
+	} else {
+		delta := h.view.advance(batch, h.opts.DeltaEpsilon)
+		entry.delta = newEncoded(delta)
+		h.log.Debug("computed stats delta",
+			slog.Uint64("sequence", delta.Sequence),
+			slog.Int("changed", len(delta.Containers)),
+			slog.Int("removed", len(delta.Removed)),
+		)
+	}
+	h.replay.push(entry)
+
+	for c := range h.clients {
+		// A client that connected with a newer snapshot from the collector
+		// already has this batch.
+		if batch.Sequence <= c.sequence || !c.sub.wants(kindStats) {
+			continue
+		}
+		if c.sub.custom() {
+			h.sendCustomBatch(c, batch)
+			continue
+		}
+		c.sequence = batch.Sequence
+		// A delta cannot replace one the client never got; a full batch can.
+		msg := full
+		if c.mode == statsModeDelta && entry.delta != nil && !c.out.pendingStats() {
+			msg = entry.delta
+		}
+		payload, ok := h.encode(c, msg)
+		if !ok && msg != full {
+			// The view is at this batch, so the full batch serves as a keyframe.
+			payload, ok = h.encode(c, full)
+		}
+		if ok {
+			h.sendStats(c, frame{payload: payload, sequence: batch.Sequence})
+		}
+	}
+}
+
+func (h *Hub) sharedDeltaClients() int {
+	count := 0
+	for c := range h.clients {
+		if c.mode == statsModeDelta && !c.sub.custom() {
+			count++
+		}
+	}
+	return count
+}
+
+// sendCustomBatch prepares batch for a client with its own filter, field
+// selection or rate. Delta clients of this kind keep a view of their own.
+func (h *Hub) sendCustomBatch(c *client, batch types.ContainerStatsBatch) {
+	now := time.Now()
+	if c.sub.interval > 0 && now.Sub(c.lastSent) < c.sub.interval {
+		return
+	}
+	if c.out.pendingStats() {
+		c.view = nil
+	}
+	filtered := c.sub.filter(batch)
+
+	var msg any
+	if c.mode == statsModeDelta && c.view != nil && now.Sub(c.lastKeyframe) < h.opts.KeyframeInterval {
+		msg = c.sub.deltaMessage(c.view.advance(filtered, h.opts.DeltaEpsilon))
+	} else {
+		if c.mode == statsModeDelta {
+			c.view = newDeltaView(filtered)
+			c.lastKeyframe = now
+		}
+		msg = c.sub.batchMessage(filtered)
+	}
+
+	payload, err := c.codec.marshal(msg)
+	if err != nil {
+		h.log.Warn("failed to encode stats batch", slog.String("encoding", c.codec.name), slog.String("error", err.Error()))
+		c.view = nil
+		return
+	}
+	c.sequence = batch.Sequence
+	c.lastSent = now
+	h.sendStats(c, frame{payload: payload, sequence: batch.Sequence})
+}
+
+// sendKeyframe gives c the latest full batch. The shared view is at the same
+// sequence, so the next delta applies on top of it.
+func (h *Hub) sendKeyframe(c *client) {
+	h.sendBatchKeyframe(c, h.latestBatch)
+}
+
+func (h *Hub) sendBatchKeyframe(c *client, batch *types.ContainerStatsBatch) {
+	if batch == nil || !c.sub.wants(kindStats) {
+		return
+	}
+	if c.sub.custom() {
+		// Dropping the view makes the next send a keyframe regardless of rate.
+		c.view = nil
+		c.lastSent = time.Time{}
+		h.sendCustomBatch(c, *batch)
+		return
+	}
+
+	msg := h.latest
+	if batch != h.latestBatch {
+		msg = newEncoded(batch)
+	}
+	payload, ok := h.encode(c, msg)
+	if !ok {
+		return
+	}
+	c.sequence = batch.Sequence
+	h.sendStats(c, frame{payload: payload, sequence: batch.Sequence})
+}
+
+// sendSnapshot brings a new client up to date before it sees live traffic: the
+// last agent status, then the freshest batch known to the hub or the collector.
+func (h *Hub) sendSnapshot(c *client) {
+	if h.status != nil && c.sub.wants(kindStatus) {
+		h.sendEncoded(c, h.status)
+	}
+	batch := h.latestBatch
+	// Shared deltas build on the view, so those clients must start from the
+	// batch it was last advanced to.
+	onView := c.mode == statsModeDelta && !c.sub.custom() && h.view != nil
+	if h.opts.LastBatch != nil && !onView {
+		if collected := h.opts.LastBatch(); collected != nil && (batch == nil || collected.Sequence > batch.Sequence) {
+			batch = collected
+		}
+	}
+	h.sendBatchKeyframe(c, batch)
+}
+
+// resume replays everything published after the batch with sequence since,
+// then continues live. Clients whose resume point has left the buffer get a
+// snapshot instead.
+func (h *Hub) resume(c *client, since uint64) {
+	entries, ok := h.replay.since(since)
+	if !ok {
+		h.log.Debug("resume point not buffered, sending snapshot", slog.Uint64("since", since))
+		h.sendSnapshot(c)
+		return
+	}
+
+	var frames []frame
+	if h.status != nil && c.sub.wants(kindStatus) {
+		if payload, ok := h.encode(c, h.status); ok {
+			frames = append(frames, frame{payload: payload})
+		}
 	}
+	for i, entry := range entries {
+		if i > 0 && c.sub.wants(kindStats) {
+			payload, err := h.replayedBatch(c, entry)
+			if err != nil {
+				h.log.Warn("failed to encode stats batch", slog.String("encoding", c.codec.name), slog.String("error", err.Error()))
+				continue
+			}
+			frames = append(frames, frame{payload: payload, sequence: entry.sequence})
+		}
+		for _, event := range entry.events {
+			if c.sub.wants(kindEvents) && c.sub.matchesEvent(event.event) {
+				if payload, ok := h.encode(c, event.message); ok {
+					frames = append(frames, frame{payload: payload})
+				}
+			}
+		}
+	}
+	last := entries[len(entries)-1]
+	c.sequence = last.sequence
+	if c.sub.custom() && c.mode == statsModeDelta {
+		// Custom clients are replayed full batches; continue deltas from the last.
+		c.view = newDeltaView(c.sub.filter(*last.batch))
+		c.lastKeyframe = time.Now()
+	}
+	h.log.Debug("replaying missed messages", slog.Uint64("since", since), slog.Int("messages", len(frames)))
+	// The replay ends with the latest batch, so anything waiting is older.
+	c.out.dropStats()
+	h.sendFrames(c, frames)
+}
+
+func (h *Hub) replayedBatch(c *client, entry replayEntry) ([]byte, error) {
+	if c.sub.custom() {
+		return c.codec.marshal(c.sub.batchMessage(c.sub.filter(*entry.batch)))
+	}
+	if c.mode == statsModeDelta && entry.delta != nil {
+		if payload, err := entry.delta.bytes(c.codec); err == nil {
+			return payload, nil
+		}
+	}
+	return entry.full.bytes(c.codec)
+}
+
+// encode returns msg in the encoding c negotiated, logging when it cannot be
+// encoded.
+func (h *Hub) encode(c *client, msg *encoded) ([]byte, bool) {
+	payload, err := msg.bytes(c.codec)
+	if err != nil {
+		h.log.Warn("failed to encode message", slog.String("encoding", c.codec.name), slog.String("error", err.Error()))
+		return nil, false
+	}
+	return payload, true
+}
+
+func (h *Hub) send(c *client, payload []byte) {
+	h.sendFrames(c, []frame{{payload: payload}})
+}
+
+func (h *Hub) sendEncoded(c *client, msg *encoded) {
+	if payload, ok := h.encode(c, msg); ok {
+		h.send(c, payload)
+	}
+}
+
+// sendFrames queues messages that must reach the client back to back.
+func (h *Hub) sendFrames(c *client, frames []frame) {
+	if !c.out.push(frames) {
+		h.dropSlowClient(c)
+	}
+}
+
+func (h *Hub) sendStats(c *client, stats frame) {
+	replaced, ok := c.out.pushStats(stats)
+	if !ok {
+		h.dropSlowClient(c)
+		return
+	}
+	if replaced {
+		c.coalesced++
+		h.coalesced.Add(1)
+	}
+}
+
+func (h *Hub) dropSlowClient(c *client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	h.dropped.Add(1)
+	h.log.Info("disconnecting slow client", slog.String("remote_addr", c.addr))
+	h.disconnect(c)
+}
+
+// newClient prepares a client from the stats_mode, since and subscription
+// query parameters shared by every endpoint.
+func (h *Hub) newClient(r *http.Request) (*client, error) {
+	query := r.URL.Query()
+	mode := statsModeFull
+	if raw := query.Get("stats_mode"); raw != "" {
+		parsed, ok := parseStatsMode(raw)
+		if !ok {
+			return nil, errors.New("unknown stats_mode")
+		}
+		mode = parsed
+	}
+	var since *uint64
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid since")
+		}
+		since = &parsed
+	}
+	sub, err := parseSubscription(query)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		out:   newOutbox(h.opts.ClientQueue, h.opts.SlowClientPolicy != PolicyDisconnect),
+		hub:   h,
+		addr:  r.RemoteAddr,
+		codec: jsonCodec,
+		since: since,
+		mode:  mode,
+		sub:   sub,
+	}, nil
 }
 
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	client, err := h.newClient(r)
 	if err != nil {
-		h.log.Warn("failed to upgrade websocket", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	client := &client{
-		conn: conn,
-		send: make(chan []byte, 16),
-		hub:  h,
+	codec, negotiated := negotiateCodec(r)
+	var header http.Header
+	if negotiated {
+		header = http.Header{"Sec-Websocket-Protocol": {codec.name}}
+	}
+
+	conn, err := h.upgrader.Upgrade(countingWriter{ResponseWriter: w, written: &h.wire}, r, header)
+	if err != nil {
+		h.log.Warn("failed to upgrade websocket", slog.String("error", err.Error()))
+		return
 	}
+	if h.opts.CompressionLevel > 0 {
+		// A no-op unless the client negotiated compression.
+		_ = conn.SetCompressionLevel(h.opts.CompressionLevel)
+	}
+
+	client.conn = conn
+	client.codec = codec
 
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		conn.Close()
+		return
+	}
 
 	go client.writePump()
 	go client.readPump()
@@ -88,30 +527,65 @@ func (h *Hub) disconnect(c *client) {
 		return
 	}
 	delete(h.clients, c)
-	close(c.send)
-	c.conn.Close()
-	h.log.Debug("client disconnected", slog.Int("clients", len(h.clients)))
+	h.connected.Store(int64(len(h.clients)))
+	c.close()
+	h.log.Debug("client disconnected", slog.Int("clients", len(h.clients)), slog.Uint64("coalesced_batches", c.coalesced))
 }
 
 func (h *Hub) shutdown() {
 	for c := range h.clients {
-		close(c.send)
-		c.conn.Close()
+		c.close()
+	}
+}
+
+func parseStatsMode(raw string) (string, bool) {
+	switch raw {
+	case statsModeFull, statsModeDelta:
+		return raw, true
 	}
+	return "", false
 }
 
 type client struct {
+	// conn is nil for SSE clients, whose handler writes the outbox instead.
 	conn *websocket.Conn
-	send chan []byte
+	out  *outbox
 	hub  *Hub
+	addr string
+	// codec encodes everything the client is sent, and binary messages it sends.
+	codec *codec
+	// since is the sequence the client asked to resume from when connecting.
+	since *uint64
+
+	// The remaining fields are owned by the hub goroutine. sequence is the last
+	// batch the client was sent; view and lastKeyframe track deltas for clients
+	// with a custom subscription, and lastSent enforces their rate. coalesced
+	// counts stats the client never got because newer ones replaced them.
+	coalesced    uint64
+	mode         string
+	sub          subscription
+	sequence     uint64
+	view         *deltaView
+	lastKeyframe time.Time
+	lastSent     time.Time
+}
+
+func (c *client) close() {
+	c.out.close()
+	if c.conn != nil {
+		c.conn.Close()
+	}
 }
 
 func (c *client) readPump() {
 	defer func() {
-		c.hub.remove <- c
+		select {
+		case c.hub.remove <- c:
+		case <-c.hub.done:
+		}
 	}()
 
-	c.conn.SetReadLimit(512)
+	c.conn.SetReadLimit(maxControlMessage)
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -119,7 +593,22 @@ func (c *client) readPump() {
 	})
 
 	for {
-		if _, _, err := c.conn.ReadMessage(); err != nil {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		decode := json.Unmarshal
+		if messageType == websocket.BinaryMessage {
+			decode = c.codec.unmarshal
+		}
+		var msg controlMessage
+		if err := decode(data, &msg); err != nil {
+			msg = controlMessage{err: err}
+		}
+		msg.client = c
+		select {
+		case c.hub.control <- msg:
+		case <-c.hub.done:
 			return
 		}
 	}
@@ -134,13 +623,12 @@ func (c *client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case <-c.out.done:
 			c.conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case <-c.out.wake:
+			if err := c.flush(); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -151,3 +639,21 @@ func (c *client) writePump() {
 		}
 	}
 }
+
+// flush writes everything waiting in the outbox.
+func (c *client) flush() error {
+	for {
+		frames, ok := c.out.next()
+		if !ok {
+			return nil
+		}
+		for _, message := range frames {
+			c.conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
+			c.conn.EnableWriteCompression(len(message.payload) >= c.hub.opts.CompressionThreshold)
+			if err := c.conn.WriteMessage(c.codec.messageType(), message.payload); err != nil {
+				return err
+			}
+			c.hub.payload.Add(uint64(len(message.payload)))
+		}
+	}
+}
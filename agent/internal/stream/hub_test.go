@@ -0,0 +1,349 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func startHub(t *testing.T, opts Options) (*Hub, string) {
+	t.Helper()
+	hub := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-done
+	})
+	return hub, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitForClients(t *testing.T, hub *Hub, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Clients() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d clients, have %d", want, hub.Clients())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// request sends a control message and waits for its ack.
+func request(t *testing.T, conn *websocket.Conn, msg map[string]any) {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if reply := readMessage(t, conn); reply.Type != "ack" || reply.Request != msg["type"] {
+		t.Fatalf("expected ack for %v, got %+v", msg, reply)
+	}
+}
+
+// message holds the fields of every message the tests look at.
+type message struct {
+	Type         string                          `json:"type"`
+	Request      string                          `json:"request"`
+	ID           string                          `json:"id"`
+	Error        string                          `json:"error"`
+	Sequence     uint64                          `json:"sequence"`
+	BaseSequence uint64                          `json:"base_sequence"`
+	Containers   []types.ContainerResourceSample `json:"containers"`
+	Removed      []string                        `json:"removed"`
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) message {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("invalid message %s: %v", data, err)
+	}
+	return msg
+}
+
+func TestHubDeltaNegotiation(t *testing.T) {
+	hub, url := startHub(t, Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour})
+	full := dial(t, url+"/ws")
+	delta := dial(t, url+"/ws?stats_mode=delta")
+	// Registration is asynchronous; a batch published before both clients are
+	// registered would be missed.
+	waitForClients(t, hub, 2)
+
+	hub.PublishBatch(testBatch(1,
+		types.ContainerResourceSample{ID: "a", CPUPct: 10},
+		types.ContainerResourceSample{ID: "b", CPUPct: 20},
+	))
+	if msg := readMessage(t, full); msg.Type != "container_stats_batch" || len(msg.Containers) != 2 {
+		t.Fatalf("full client got %+v", msg)
+	}
+	if msg := readMessage(t, delta); msg.Type != "container_stats_batch" || msg.Sequence != 1 {
+		t.Fatalf("delta client should start with a keyframe, got %+v", msg)
+	}
+
+	hub.PublishBatch(testBatch(2, types.ContainerResourceSample{ID: "a", CPUPct: 30}))
+	if msg := readMessage(t, full); msg.Type != "container_stats_batch" || len(msg.Containers) != 1 {
+		t.Fatalf("full client got %+v", msg)
+	}
+	msg := readMessage(t, delta)
+	if msg.Type != "container_stats_delta" || msg.BaseSequence != 1 || len(msg.Containers) != 1 || len(msg.Removed) != 1 || msg.Removed[0] != "b" {
+		t.Fatalf("unexpected delta: %+v", msg)
+	}
+
+	request(t, delta, map[string]any{"type": "request_keyframe"})
+	if msg := readMessage(t, delta); msg.Type != "container_stats_batch" || msg.Sequence != 2 {
+		t.Fatalf("expected requested keyframe, got %+v", msg)
+	}
+}
+
+func TestHubSwitchesModeOnRequest(t *testing.T) {
+	// Without coalescing every batch is sent, so the deltas are predictable.
+	hub, url := startHub(t, Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour, SlowClientPolicy: PolicyDisconnect})
+	conn := dial(t, url+"/ws")
+	waitForClients(t, hub, 1)
+
+	hub.PublishBatch(testBatch(1, types.ContainerResourceSample{ID: "a", CPUPct: 10}))
+	readMessage(t, conn)
+
+	request(t, conn, map[string]any{"type": "set_stats_mode", "mode": "delta"})
+	if msg := readMessage(t, conn); msg.Type != "container_stats_batch" || msg.Sequence != 1 {
+		t.Fatalf("expected keyframe after switching, got %+v", msg)
+	}
+
+	hub.PublishBatch(testBatch(2, types.ContainerResourceSample{ID: "a", CPUPct: 10}))
+	hub.PublishBatch(testBatch(3, types.ContainerResourceSample{ID: "a", CPUPct: 10.01}))
+	for seq := uint64(2); seq <= 3; seq++ {
+		msg := readMessage(t, conn)
+		if msg.Sequence != seq {
+			t.Fatalf("expected sequence %d, got %+v", seq, msg)
+		}
+		if seq == 3 && (msg.Type != "container_stats_delta" || len(msg.Containers) != 0) {
+			t.Fatalf("expected an empty delta, got %+v", msg)
+		}
+	}
+}
+
+func TestHubCoalescesDeltasForClientKeepingUp(t *testing.T) {
+	hub, url := startHub(t, Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour, SlowClientPolicy: PolicyCoalesce})
+	conn := dial(t, url+"/ws?stats_mode=delta")
+	waitForClients(t, hub, 1)
+
+	hub.PublishBatch(testBatch(1, types.ContainerResourceSample{ID: "a", CPUPct: 10}))
+	readMessage(t, conn)
+
+	// Batch 3 can arrive before the writer sent batch 2. Batch 2 is then
+	// skipped and batch 3 must be sent in full, as a delta would not apply.
+	hub.PublishBatch(testBatch(2, types.ContainerResourceSample{ID: "a", CPUPct: 20}))
+	hub.PublishBatch(testBatch(3, types.ContainerResourceSample{ID: "a", CPUPct: 30}))
+	msg := readMessage(t, conn)
+	if msg.Sequence == 2 {
+		msg = readMessage(t, conn)
+		if msg.Sequence != 3 || len(msg.Containers) != 1 || msg.Containers[0].CPUPct != 30 {
+			t.Fatalf("expected batch 3 after batch 2, got %+v", msg)
+		}
+		return
+	}
+	if msg.Type != "container_stats_batch" || msg.Sequence != 3 {
+		t.Fatalf("expected batch 2 to be replaced by a full batch 3, got %+v", msg)
+	}
+}
+
+func TestHubRejectsUnknownStatsMode(t *testing.T) {
+	_, url := startHub(t, Options{KeyframeInterval: time.Hour})
+	_, resp, err := websocket.DefaultDialer.Dial(url+"/ws?stats_mode=diff", nil)
+	if err == nil || resp == nil || resp.StatusCode != 400 {
+		t.Fatalf("expected 400, got %v %v", resp, err)
+	}
+}
+
+func TestHubSendsSnapshotOnConnect(t *testing.T) {
+	var collected atomic.Pointer[types.ContainerStatsBatch]
+	hub, url := startHub(t, Options{
+		KeyframeInterval: time.Hour,
+		LastBatch:        collected.Load,
+	})
+	first := dial(t, url+"/ws")
+	waitForClients(t, hub, 1)
+
+	hub.PublishStatus(types.AgentStatusMessage{Type: "agent_status", AgentID: "agent"})
+	hub.PublishBatch(testBatch(1, types.ContainerResourceSample{ID: "a"}))
+	// Once the first client has both, the hub has cached them.
+	readMessage(t, first)
+	readMessage(t, first)
+
+	second := dial(t, url+"/ws")
+	if msg := readMessage(t, second); msg.Type != "agent_status" {
+		t.Fatalf("expected status first, got %+v", msg)
+	}
+	if msg := readMessage(t, second); msg.Type != "container_stats_batch" || msg.Sequence != 1 {
+		t.Fatalf("expected cached batch, got %+v", msg)
+	}
+
+	// The collector can be ahead of the hub; the newer batch wins and the stale
+	// one is not repeated when it arrives.
+	collected.Store(&types.ContainerStatsBatch{Type: "container_stats_batch", Sequence: 2})
+	third := dial(t, url+"/ws")
+	readMessage(t, third)
+	if msg := readMessage(t, third); msg.Sequence != 2 {
+		t.Fatalf("expected collector batch, got %+v", msg)
+	}
+	hub.PublishBatch(testBatch(2))
+	hub.PublishBatch(testBatch(3))
+	if msg := readMessage(t, third); msg.Sequence != 3 {
+		t.Fatalf("expected sequence 3, got %+v", msg)
+	}
+}
+
+func TestHubResumesFromSequence(t *testing.T) {
+	hub, url := startHub(t, Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour, ReplayBatches: 8})
+	// A delta client keeps deltas flowing into the replay buffer.
+	live := dial(t, url+"/ws?stats_mode=delta")
+	waitForClients(t, hub, 1)
+
+	hub.PublishBatch(testBatch(1, types.ContainerResourceSample{ID: "a", CPUPct: 10}))
+	readMessage(t, live)
+	hub.PublishEvent(types.ContainerEventMessage{Type: "container_event", ContainerID: "b", Action: "start"})
+	readMessage(t, live)
+	hub.PublishBatch(testBatch(2,
+		types.ContainerResourceSample{ID: "a", CPUPct: 10},
+		types.ContainerResourceSample{ID: "b", CPUPct: 5},
+	))
+	readMessage(t, live)
+	hub.PublishBatch(testBatch(3, types.ContainerResourceSample{ID: "a", CPUPct: 20}))
+	readMessage(t, live)
+
+	full := dial(t, url+"/ws?since=1")
+	want := []struct {
+		kind     string
+		sequence uint64
+	}{{"container_event", 1}, {"container_stats_batch", 2}, {"container_stats_batch", 3}}
+	for _, w := range want {
+		if msg := readMessage(t, full); msg.Type != w.kind || msg.Sequence != w.sequence {
+			t.Fatalf("expected %s %d, got %+v", w.kind, w.sequence, msg)
+		}
+	}
+
+	delta := dial(t, url+"/ws?stats_mode=delta")
+	readMessage(t, delta)
+	request(t, delta, map[string]any{"type": "resume", "since": 2})
+	msg := readMessage(t, delta)
+	if msg.Type != "container_stats_delta" || msg.BaseSequence != 2 || len(msg.Removed) != 1 {
+		t.Fatalf("expected replayed delta, got %+v", msg)
+	}
+
+	// A resume point that has left the buffer falls back to the latest batch.
+	stale := dial(t, url+"/ws?since=42")
+	if msg := readMessage(t, stale); msg.Type != "container_stats_batch" || msg.Sequence != 3 {
+		t.Fatalf("expected keyframe, got %+v", msg)
+	}
+}
+
+func TestHubControlProtocol(t *testing.T) {
+	hub, url := startHub(t, Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour})
+	conn := dial(t, url+"/ws")
+	waitForClients(t, hub, 1)
+
+	request(t, conn, map[string]any{"type": "unsubscribe", "messages": []string{"container_event", "agent_status"}})
+	request(t, conn, map[string]any{"type": "set_filter", "names": []string{"web-*"}})
+	request(t, conn, map[string]any{"type": "set_rate", "interval": "1h"})
+
+	hub.PublishStatus(types.AgentStatusMessage{Type: "agent_status"})
+	hub.PublishEvent(types.ContainerEventMessage{Type: "container_event", ContainerName: "web-1", Action: "start"})
+	hub.PublishBatch(testBatch(1,
+		types.ContainerResourceSample{ID: "a", Name: "web-1"},
+		types.ContainerResourceSample{ID: "b", Name: "db"},
+	))
+	// Rate limited: only the first of these two batches is sent.
+	hub.PublishBatch(testBatch(2, types.ContainerResourceSample{ID: "a", Name: "web-1"}))
+	msg := readMessage(t, conn)
+	if msg.Type != "container_stats_batch" || msg.Sequence != 1 || len(msg.Containers) != 1 || msg.Containers[0].ID != "a" {
+		t.Fatalf("expected filtered batch, got %+v", msg)
+	}
+
+	if err := conn.WriteJSON(map[string]any{"type": "set_fields", "id": "7", "fields": []string{"cpu"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if reply := readMessage(t, conn); reply.Type != "error" || reply.ID != "7" || reply.Request != "set_fields" || reply.Error == "" {
+		t.Fatalf("expected error reply, got %+v", reply)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if reply := readMessage(t, conn); reply.Type != "error" {
+		t.Fatalf("expected error for invalid JSON, got %+v", reply)
+	}
+
+	// Resubscribing to events does not replay the one sent while unsubscribed.
+	request(t, conn, map[string]any{"type": "subscribe", "messages": []string{"container_event"}})
+	hub.PublishEvent(types.ContainerEventMessage{Type: "container_event", ContainerName: "db", Action: "die"})
+	hub.PublishEvent(types.ContainerEventMessage{Type: "container_event", ContainerName: "web-2", Action: "start"})
+	if msg := readMessage(t, conn); msg.Type != "container_event" {
+		t.Fatalf("expected matching event, got %+v", msg)
+	}
+}
+
+func TestHubCompressesLargeMessages(t *testing.T) {
+	hub, url := startHub(t, Options{KeyframeInterval: time.Hour, CompressionLevel: 6, CompressionThreshold: 512})
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial(url+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("compression not negotiated: %q", ext)
+	}
+	waitForClients(t, hub, 1)
+
+	samples := make([]types.ContainerResourceSample, 200)
+	for i := range samples {
+		samples[i] = types.ContainerResourceSample{ID: fmt.Sprintf("container-%03d", i), Name: "web", CPUPct: 12.5}
+	}
+	hub.PublishBatch(testBatch(1, samples...))
+	if msg := readMessage(t, conn); len(msg.Containers) != 200 {
+		t.Fatalf("expected 200 containers, got %d", len(msg.Containers))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	metrics := hub.Metrics()
+	for metrics.PayloadBytes == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		metrics = hub.Metrics()
+	}
+	if metrics.PayloadBytes == 0 || metrics.WireBytes*4 > metrics.PayloadBytes {
+		t.Fatalf("expected the batch to shrink, got %d bytes for %d", metrics.WireBytes, metrics.PayloadBytes)
+	}
+}
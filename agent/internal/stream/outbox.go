@@ -0,0 +1,133 @@
+package stream
+
+import "sync"
+
+// Slow client policies.
+const (
+	PolicyCoalesce   = "coalesce"
+	PolicyDisconnect = "disconnect"
+)
+
+// frame is one message for a client. sequence is the batch a stats message
+// carries and zero for anything else; SSE clients get it as the event ID.
+type frame struct {
+	payload  []byte
+	sequence uint64
+}
+
+// outbox holds what is waiting to be written to one client. Control replies and
+// events are queued in order; with coalescing, stats wait in a single slot that
+// newer stats overwrite, so a slow client skips snapshots instead of falling
+// further behind. Stats are still written in the order they were offered:
+// statsAhead counts the queued entries that came before them.
+type outbox struct {
+	mu         sync.Mutex
+	queue      [][]frame
+	stats      *frame
+	statsAhead int
+	limit      int
+	coalesce   bool
+	closed     bool
+
+	// wake is signalled when something is added; done is closed with the outbox.
+	wake chan struct{}
+	done chan struct{}
+}
+
+func newOutbox(limit int, coalesce bool) *outbox {
+	return &outbox{
+		limit:    max(limit, 1),
+		coalesce: coalesce,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// push queues frames to be written back to back. It reports false when the queue
+// is full.
+func (o *outbox) push(frames []frame) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return true
+	}
+	if len(o.queue) >= o.limit {
+		return false
+	}
+	o.queue = append(o.queue, frames)
+	o.signal()
+	return true
+}
+
+// pushStats offers a stats message, reporting whether it replaced one that was
+// never written, and false when it could not be queued at all.
+func (o *outbox) pushStats(stats frame) (replaced, ok bool) {
+	if !o.coalesce {
+		return false, o.push([]frame{stats})
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return false, true
+	}
+	replaced = o.stats != nil
+	o.stats = &stats
+	o.statsAhead = len(o.queue)
+	o.signal()
+	return replaced, true
+}
+
+// pendingStats reports whether a stats message is waiting in the slot.
+func (o *outbox) pendingStats() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stats != nil
+}
+
+// dropStats discards the waiting stats message, for when queued messages already
+// bring the client further.
+func (o *outbox) dropStats() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stats = nil
+}
+
+// next returns the frames to write next, in the order they were offered.
+func (o *outbox) next() ([]frame, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stats != nil && o.statsAhead == 0 {
+		stats := *o.stats
+		o.stats = nil
+		return []frame{stats}, true
+	}
+	if len(o.queue) > 0 {
+		frames := o.queue[0]
+		o.queue[0] = nil
+		o.queue = o.queue[1:]
+		if o.statsAhead > 0 {
+			o.statsAhead--
+		}
+		return frames, true
+	}
+	return nil, false
+}
+
+func (o *outbox) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	o.closed = true
+	o.queue = nil
+	o.stats = nil
+	close(o.done)
+}
+
+func (o *outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func drain(out *outbox) []string {
+	var written []string
+	for {
+		frames, ok := out.next()
+		if !ok {
+			return written
+		}
+		for _, f := range frames {
+			written = append(written, string(f.payload))
+		}
+	}
+}
+
+func TestOutboxCoalescesStats(t *testing.T) {
+	out := newOutbox(2, true)
+	out.pushStats(frame{payload: []byte("batch-1"), sequence: 1})
+	out.push([]frame{{payload: []byte("event")}})
+	if replaced, ok := out.pushStats(frame{payload: []byte("batch-2"), sequence: 2}); !replaced || !ok {
+		t.Fatalf("expected batch-1 to be replaced")
+	}
+
+	if written := drain(out); len(written) != 2 || written[0] != "event" || written[1] != "batch-2" {
+		t.Fatalf("unexpected write order: %v", written)
+	}
+}
+
+func TestOutboxWritesStatsBeforeLaterEvents(t *testing.T) {
+	out := newOutbox(4, true)
+	out.push([]frame{{payload: []byte("reply")}})
+	out.pushStats(frame{payload: []byte("batch-1"), sequence: 1})
+	out.push([]frame{{payload: []byte("event")}})
+
+	// The event refers to batch 1, so it must not overtake it.
+	written := drain(out)
+	if len(written) != 3 || written[0] != "reply" || written[1] != "batch-1" || written[2] != "event" {
+		t.Fatalf("unexpected write order: %v", written)
+	}
+}
+
+func TestOutboxQueueLimit(t *testing.T) {
+	out := newOutbox(2, false)
+	for i := 0; i < 2; i++ {
+		if _, ok := out.pushStats(frame{payload: []byte("batch")}); !ok {
+			t.Fatalf("push %d rejected", i)
+		}
+	}
+	if _, ok := out.pushStats(frame{payload: []byte("batch")}); ok {
+		t.Fatalf("expected full queue to reject stats without coalescing")
+	}
+	if out.push([]frame{{payload: []byte("event")}}) {
+		t.Fatalf("expected full queue to reject events")
+	}
+}
+
+func TestHubCoalescedDeltaFallsBackToFullBatch(t *testing.T) {
+	hub := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), Options{DeltaEpsilon: 0.01, KeyframeInterval: time.Hour})
+	c := &client{hub: hub, out: newOutbox(4, true), codec: jsonCodec, mode: statsModeDelta}
+	hub.clients[c] = struct{}{}
+
+	// Nothing is written in between, as with a client that stopped reading.
+	for seq := uint64(1); seq <= 3; seq++ {
+		hub.publishBatch(testBatch(seq, types.ContainerResourceSample{ID: "a", CPUPct: float64(seq * 10)}))
+	}
+
+	frames, ok := c.out.next()
+	if !ok || len(frames) != 1 {
+		t.Fatalf("expected one pending message, got %d", len(frames))
+	}
+	var msg message
+	if err := json.Unmarshal(frames[0].payload, &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if msg.Type != "container_stats_batch" || msg.Sequence != 3 {
+		t.Fatalf("a replaced delta must become a full batch, got %+v", msg)
+	}
+	if metrics := hub.Metrics(); metrics.CoalescedBatches != 2 || c.coalesced != 2 {
+		t.Fatalf("unexpected coalesced count: %+v", metrics)
+	}
+}
@@ -0,0 +1,84 @@
+package stream
+
+import "github.com/your-org/docker-stats-dashboard/agent/internal/types"
+
+// replayEntry is one published batch together with the events that followed it,
+// kept so a reconnecting client can catch up.
+type replayEntry struct {
+	sequence uint64
+	batch    *types.ContainerStatsBatch
+	full     *encoded
+	// delta is the delta from the previous entry, or nil when the batch went out
+	// as a keyframe.
+	delta  *encoded
+	events []replayEvent
+}
+
+// replayEvent keeps the event alongside its encodings so it can be filtered per
+// client when replayed.
+type replayEvent struct {
+	event   types.ContainerEventMessage
+	message *encoded
+}
+
+// replayBuffer is a fixed-size ring of the most recent batches.
+type replayBuffer struct {
+	entries []replayEntry
+	start   int
+	size    int
+}
+
+func newReplayBuffer(capacity int) *replayBuffer {
+	return &replayBuffer{entries: make([]replayEntry, max(capacity, 0))}
+}
+
+func (b *replayBuffer) at(i int) *replayEntry {
+	return &b.entries[(b.start+i)%len(b.entries)]
+}
+
+func (b *replayBuffer) push(entry replayEntry) {
+	if len(b.entries) == 0 {
+		return
+	}
+	// A resent batch keeps its sequence; replace it rather than replay it twice.
+	if b.size > 0 {
+		if last := b.at(b.size - 1); last.sequence == entry.sequence {
+			entry.events = last.events
+			*last = entry
+			return
+		}
+	}
+	if b.size == len(b.entries) {
+		b.entries[b.start] = replayEntry{}
+		b.start = (b.start + 1) % len(b.entries)
+		b.size--
+	}
+	*b.at(b.size) = entry
+	b.size++
+}
+
+// addEvent records an event after the newest batch. Events published before the
+// first batch are not kept.
+func (b *replayBuffer) addEvent(event replayEvent) {
+	if b.size == 0 {
+		return
+	}
+	last := b.at(b.size - 1)
+	last.events = append(last.events, event)
+}
+
+// since returns the entries from sequence on, the first of which the client
+// already has. It reports false when sequence is no longer, or was never, buffered.
+func (b *replayBuffer) since(sequence uint64) ([]replayEntry, bool) {
+	for i := 0; i < b.size; i++ {
+		if b.at(i).sequence != sequence {
+			continue
+		}
+		entries := make([]replayEntry, 0, b.size-i)
+		for j := i; j < b.size; j++ {
+			entries = append(entries, *b.at(j))
+		}
+		return entries, true
+	}
+	return nil, false
+}
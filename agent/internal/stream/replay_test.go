@@ -0,0 +1,47 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func TestReplayBufferEvictsOldest(t *testing.T) {
+	buffer := newReplayBuffer(3)
+	for seq := uint64(1); seq <= 5; seq++ {
+		buffer.push(replayEntry{sequence: seq})
+		buffer.addEvent(replayEvent{event: types.ContainerEventMessage{Sequence: seq}})
+	}
+
+	if _, ok := buffer.since(2); ok {
+		t.Fatalf("sequence 2 should have been evicted")
+	}
+	entries, ok := buffer.since(3)
+	if !ok || len(entries) != 3 || entries[0].sequence != 3 || entries[2].sequence != 5 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if len(entries[1].events) != 1 || entries[1].events[0].event.Sequence != 4 {
+		t.Fatalf("events not kept with their batch: %+v", entries[1])
+	}
+}
+
+func TestReplayBufferMergesResentBatch(t *testing.T) {
+	buffer := newReplayBuffer(4)
+	buffer.push(replayEntry{sequence: 1, full: newEncoded("a")})
+	buffer.addEvent(replayEvent{})
+	buffer.push(replayEntry{sequence: 1, full: newEncoded("b")})
+
+	entries, ok := buffer.since(1)
+	if !ok || len(entries) != 1 || entries[0].full.value != "b" || len(entries[0].events) != 1 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestReplayBufferDisabled(t *testing.T) {
+	buffer := newReplayBuffer(0)
+	buffer.push(replayEntry{sequence: 1})
+	buffer.addEvent(replayEvent{})
+	if _, ok := buffer.since(1); ok {
+		t.Fatalf("disabled buffer should not resume")
+	}
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"bufio"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ServeSSE streams the same messages as ServeWS as Server-Sent Events, for
+// clients behind proxies that break WebSocket upgrades. Stats messages carry
+// their sequence as the event ID, so a reconnecting EventSource resumes through
+// Last-Event-ID. There is no control channel; the subscription is fixed by the
+// query parameters.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	c, err := h.newClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		since, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		c.since = &since
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stops nginx and similar proxies from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.log.Warn("failed to start event stream", slog.String("error", err.Error()))
+		return
+	}
+
+	select {
+	case h.register <- c:
+	case <-h.done:
+		return
+	}
+	defer func() {
+		select {
+		case h.remove <- c:
+		case <-h.done:
+		}
+	}()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	out := bufio.NewWriter(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.out.done:
+			return
+		case <-c.out.wake:
+			rc.SetWriteDeadline(time.Now().Add(15 * time.Second))
+			for {
+				frames, ok := c.out.next()
+				if !ok {
+					break
+				}
+				for _, message := range frames {
+					writeEvent(out, message)
+				}
+			}
+			if out.Flush() != nil || rc.Flush() != nil {
+				return
+			}
+		case <-ticker.C:
+			// A comment keeps proxies from closing an idle stream.
+			rc.SetWriteDeadline(time.Now().Add(15 * time.Second))
+			out.WriteString(": keepalive\n\n")
+			if out.Flush() != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes one message as an event. Messages are single-line JSON, so
+// one data field holds them.
+func writeEvent(out *bufio.Writer, message frame) {
+	if message.sequence > 0 {
+		out.WriteString("id: ")
+		out.WriteString(strconv.FormatUint(message.sequence, 10))
+		out.WriteByte('\n')
+	}
+	out.WriteString("data: ")
+	out.Write(message.payload)
+	out.WriteString("\n\n")
+}
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// sseEvent is one event read from a stream, with its data decoded.
+type sseEvent struct {
+	id  string
+	msg message
+}
+
+func startSSE(t *testing.T, hub *Hub) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeSSE))
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		server.Close()
+	})
+	return server.URL
+}
+
+func openSSE(t *testing.T, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response: %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func readEvent(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.msg); err != nil {
+				t.Fatalf("invalid data %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestHubServesSSE(t *testing.T) {
+	hub := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), Options{KeyframeInterval: time.Hour, ReplayBatches: 8})
+	url := startSSE(t, hub)
+	stream := openSSE(t, url+"?names=web-*", "")
+	waitForClients(t, hub, 1)
+
+	hub.PublishBatch(testBatch(1,
+		types.ContainerResourceSample{ID: "a", Name: "web-1"},
+		types.ContainerResourceSample{ID: "b", Name: "db"},
+	))
+	event := readEvent(t, stream)
+	if event.id != "1" || event.msg.Type != "container_stats_batch" || len(event.msg.Containers) != 1 {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	hub.PublishEvent(types.ContainerEventMessage{Type: "container_event", ContainerName: "web-1", Action: "restart"})
+	if event := readEvent(t, stream); event.id != "" || event.msg.Type != "container_event" || event.msg.Sequence != 1 {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	hub.PublishBatch(testBatch(2, types.ContainerResourceSample{ID: "a", Name: "web-1"}))
+	hub.PublishBatch(testBatch(3, types.ContainerResourceSample{ID: "a", Name: "web-1"}))
+	// The stream may skip batch 2 when it is coalesced.
+	for readEvent(t, stream).id != "3" {
+	}
+
+	// Events after the resume point are replayed with the batches.
+	resumed := openSSE(t, url, "1")
+	if event := readEvent(t, resumed); event.msg.Type != "container_event" {
+		t.Fatalf("expected the missed event, got %+v", event)
+	}
+	for _, want := range []string{"2", "3"} {
+		if event := readEvent(t, resumed); event.id != want || event.msg.Type != "container_stats_batch" {
+			t.Fatalf("expected batch %s, got %+v", want, event)
+		}
+	}
+}
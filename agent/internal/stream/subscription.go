@@ -0,0 +1,260 @@
+package stream
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+// Message kinds a client can subscribe to. Stats covers full batches and deltas.
+const (
+	kindStatus = "agent_status"
+	kindStats  = "container_stats"
+	kindEvents = "container_event"
+)
+
+var messageKinds = []string{kindStatus, kindStats, kindEvents}
+
+// sampleFields maps the JSON name of every sample field to its index, for
+// validating and applying field selections.
+var sampleFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(types.ContainerResourceSample{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = i
+	}
+	return fields
+}()
+
+// subscription is what a client asked to receive. The zero value is everything,
+// at every batch.
+type subscription struct {
+	// kinds is nil when every message kind is wanted.
+	kinds  map[string]bool
+	names  []string
+	labels []labelSelector
+	// fields holds sample field indexes; nil selects every field.
+	fields   []int
+	interval time.Duration
+}
+
+// labelSelector matches a container label, by key alone when value is empty.
+type labelSelector struct {
+	key   string
+	value string
+}
+
+func (s subscription) wants(kind string) bool {
+	return s.kinds == nil || s.kinds[kind]
+}
+
+// custom reports whether stats for this client must be prepared for it alone
+// rather than shared with every other client.
+func (s subscription) custom() bool {
+	return len(s.names) > 0 || len(s.labels) > 0 || s.fields != nil || s.interval > 0
+}
+
+func (s subscription) matches(name string, labels map[string]string) bool {
+	if len(s.names) > 0 {
+		matched := false
+		for _, pattern := range s.names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, selector := range s.labels {
+		value, ok := labels[selector.key]
+		if !ok || (selector.value != "" && value != selector.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s subscription) matchesEvent(event types.ContainerEventMessage) bool {
+	return s.matches(event.ContainerName, event.Labels)
+}
+
+// filter keeps the containers the client asked for, with unselected fields
+// zeroed so they cannot make a container look changed in a delta.
+func (s subscription) filter(batch types.ContainerStatsBatch) types.ContainerStatsBatch {
+	containers := make([]types.ContainerResourceSample, 0, len(batch.Containers))
+	for _, sample := range batch.Containers {
+		if !s.matches(sample.Name, sample.Labels) {
+			continue
+		}
+		if s.fields != nil {
+			sample = s.strip(sample)
+		}
+		containers = append(containers, sample)
+	}
+	batch.Containers = containers
+	return batch
+}
+
+func (s subscription) strip(sample types.ContainerResourceSample) types.ContainerResourceSample {
+	var stripped types.ContainerResourceSample
+	src := reflect.ValueOf(sample)
+	dst := reflect.ValueOf(&stripped).Elem()
+	dst.Field(sampleFields["id"]).Set(src.Field(sampleFields["id"]))
+	for _, index := range s.fields {
+		dst.Field(index).Set(src.Field(index))
+	}
+	return stripped
+}
+
+// project returns the containers as encoded for the client: only the selected
+// fields, plus the ID.
+func (s subscription) project(samples []types.ContainerResourceSample) any {
+	if s.fields == nil {
+		return samples
+	}
+	projected := make([]map[string]any, 0, len(samples))
+	for _, sample := range samples {
+		value := reflect.ValueOf(sample)
+		entry := map[string]any{"id": sample.ID}
+		for _, index := range s.fields {
+			name, _, _ := strings.Cut(value.Type().Field(index).Tag.Get("json"), ",")
+			entry[name] = value.Field(index).Interface()
+		}
+		projected = append(projected, entry)
+	}
+	return projected
+}
+
+// projectedBatch and projectedDelta replace the containers of a message with a
+// field selection; the outer field shadows the embedded one when encoding.
+// MessagePack only skips a shadowed field that was declared before an
+// explicitly inlined struct.
+type projectedBatch struct {
+	Containers                any `json:"containers"`
+	types.ContainerStatsBatch `json:",inline"`
+}
+
+type projectedDelta struct {
+	Containers                any `json:"containers"`
+	types.ContainerStatsDelta `json:",inline"`
+}
+
+func (s subscription) batchMessage(batch types.ContainerStatsBatch) any {
+	return projectedBatch{ContainerStatsBatch: batch, Containers: s.project(batch.Containers)}
+}
+
+func (s subscription) deltaMessage(delta types.ContainerStatsDelta) any {
+	return projectedDelta{ContainerStatsDelta: delta, Containers: s.project(delta.Containers)}
+}
+
+func parseKinds(values []string) (map[string]bool, error) {
+	kinds := make(map[string]bool, len(values))
+	for _, value := range values {
+		if !isMessageKind(value) {
+			return nil, fmt.Errorf("unknown message type %q: must be one of %s", value, strings.Join(messageKinds, ", "))
+		}
+		kinds[value] = true
+	}
+	return kinds, nil
+}
+
+func isMessageKind(value string) bool {
+	for _, kind := range messageKinds {
+		if value == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNames(values []string) ([]string, error) {
+	for _, pattern := range values {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q", pattern)
+		}
+	}
+	return values, nil
+}
+
+func parseLabels(values []string) ([]labelSelector, error) {
+	selectors := make([]labelSelector, 0, len(values))
+	for _, raw := range values {
+		key, value, _ := strings.Cut(raw, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid label selector %q", raw)
+		}
+		selectors = append(selectors, labelSelector{key: key, value: value})
+	}
+	return selectors, nil
+}
+
+func parseFields(values []string) ([]int, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	fields := make([]int, 0, len(values))
+	for _, name := range values {
+		index, ok := sampleFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if name != "id" {
+			fields = append(fields, index)
+		}
+	}
+	return fields, nil
+}
+
+func parseInterval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid interval %q", raw)
+	}
+	return interval, nil
+}
+
+// parseSubscription reads a subscription from query parameters, which take the
+// same values as the control messages with lists comma separated.
+func parseSubscription(query url.Values) (subscription, error) {
+	var sub subscription
+	var err error
+	if raw := query.Get("messages"); raw != "" {
+		if sub.kinds, err = parseKinds(splitParam(raw)); err != nil {
+			return subscription{}, err
+		}
+	}
+	if sub.names, err = parseNames(splitParam(query.Get("names"))); err != nil {
+		return subscription{}, err
+	}
+	if sub.labels, err = parseLabels(splitParam(query.Get("labels"))); err != nil {
+		return subscription{}, err
+	}
+	if sub.fields, err = parseFields(splitParam(query.Get("fields"))); err != nil {
+		return subscription{}, err
+	}
+	if sub.interval, err = parseInterval(query.Get("interval")); err != nil {
+		return subscription{}, err
+	}
+	return sub, nil
+}
+
+func splitParam(raw string) []string {
+	var values []string
+	for _, part := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
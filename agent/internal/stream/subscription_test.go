@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func TestParseSubscriptionQuery(t *testing.T) {
+	query, _ := url.ParseQuery("messages=container_stats&names=web-*,api&labels=tier=frontend,team&fields=cpu_pct,mem_bytes&interval=2s")
+	sub, err := parseSubscription(query)
+	if err != nil {
+		t.Fatalf("parseSubscription returned error: %v", err)
+	}
+	if !sub.wants(kindStats) || sub.wants(kindEvents) || sub.wants(kindStatus) {
+		t.Fatalf("unexpected kinds: %v", sub.kinds)
+	}
+	if len(sub.names) != 2 || len(sub.labels) != 2 || sub.labels[0] != (labelSelector{"tier", "frontend"}) || sub.labels[1] != (labelSelector{key: "team"}) {
+		t.Fatalf("unexpected filter: %+v", sub)
+	}
+	if len(sub.fields) != 2 || sub.interval != 2*time.Second || !sub.custom() {
+		t.Fatalf("unexpected selection: %+v", sub)
+	}
+
+	for _, raw := range []string{"messages=stats", "names=[", "labels==x", "fields=cpu", "interval=-1s"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := parseSubscription(query); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestSubscriptionFilterAndProject(t *testing.T) {
+	sub := subscription{
+		names:  []string{"web-*"},
+		labels: []labelSelector{{key: "tier", value: "frontend"}},
+	}
+	sub.fields, _ = parseFields([]string{"cpu_pct", "name"})
+
+	batch := sub.filter(types.ContainerStatsBatch{Containers: []types.ContainerResourceSample{
+		{ID: "1", Name: "web-1", CPUPct: 5, MemBytes: 10, Labels: map[string]string{"tier": "frontend"}},
+		{ID: "2", Name: "web-2", Labels: map[string]string{"tier": "backend"}},
+		{ID: "3", Name: "db", Labels: map[string]string{"tier": "frontend"}},
+	}})
+	if len(batch.Containers) != 1 || batch.Containers[0].ID != "1" || batch.Containers[0].MemBytes != 0 {
+		t.Fatalf("unexpected filtered containers: %+v", batch.Containers)
+	}
+
+	data, err := json.Marshal(sub.batchMessage(batch))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded struct {
+		Containers []map[string]any `json:"containers"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(decoded.Containers) != 1 || len(decoded.Containers[0]) != 3 || decoded.Containers[0]["cpu_pct"] != 5.0 {
+		t.Fatalf("unexpected projection: %s", data)
+	}
+}
@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// tokenProtocolPrefix marks a WebSocket subprotocol that carries a token, for
+// browsers, which cannot set headers on WebSocket requests.
+const tokenProtocolPrefix = "token."
+
+// namedToken is a token from the tokens file. Only its hash is kept, so every
+// comparison is over the same length.
+type namedToken struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// tokenAuth accepts requests carrying one of its tokens.
+type tokenAuth struct {
+	logger *slog.Logger
+	tokens []namedToken
+}
+
+// loadTokens reads a tokens file: one "name:token" per line, with blank lines
+// and lines starting with # ignored.
+func loadTokens(logger *slog.Logger, path string) (*tokenAuth, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	auth := &tokenAuth{logger: logger}
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, token, ok := strings.Cut(text, ":")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("%s:%d: expected name:token", path, line)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("%s:%d: duplicate token name %q", path, line, name)
+		}
+		names[name] = true
+		auth.tokens = append(auth.tokens, namedToken{name: name, hash: sha256.Sum256([]byte(token))})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(auth.tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return auth, nil
+}
+
+// lookup returns the name of token. Every token is compared so the time taken
+// does not depend on which one matched.
+func (a *tokenAuth) lookup(token string) (string, bool) {
+	hash := sha256.Sum256([]byte(token))
+	name := ""
+	for _, candidate := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], candidate.hash[:]) == 1 {
+			name = candidate.name
+		}
+	}
+	return name, name != ""
+}
+
+// requestToken finds the token in the Authorization or X-API-Key header, a
+// "token.<token>" WebSocket subprotocol, or the token query parameter.
+func requestToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		return token
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		if token, ok := strings.CutPrefix(protocol, tokenProtocolPrefix); ok {
+			return token
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+// require answers requests without a valid token with 401, before next runs and
+// so before any WebSocket upgrade.
+func (a *tokenAuth) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, ok := a.lookup(requestToken(r))
+		if !ok {
+			a.logger.Info("rejected unauthenticated request", slog.String("path", r.URL.Path), slog.String("remote_addr", r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="docker-agent"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		a.logger.Debug("authenticated request", slog.String("path", r.URL.Path), slog.String("token", name))
+		next(w, r)
+	}
+}
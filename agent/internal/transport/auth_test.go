@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/stream"
+)
+
+func writeTokens(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write tokens: %v", err)
+	}
+	return path
+}
+
+func TestLoadTokens(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth, err := loadTokens(logger, writeTokens(t, "# dashboards\nsite-a: s3cret\n\nsite-b:other:with:colons\n"))
+	if err != nil {
+		t.Fatalf("loadTokens returned error: %v", err)
+	}
+	if name, ok := auth.lookup("s3cret"); !ok || name != "site-a" {
+		t.Fatalf("expected site-a, got %q", name)
+	}
+	if name, ok := auth.lookup("other:with:colons"); !ok || name != "site-b" {
+		t.Fatalf("expected site-b, got %q", name)
+	}
+	for _, token := range []string{"", "s3cre", "s3cret "} {
+		if _, ok := auth.lookup(token); ok {
+			t.Fatalf("token %q should not match", token)
+		}
+	}
+
+	for _, content := range []string{"", "# nothing\n", "no-separator\n", "a:x\na:y\n", ":token\n"} {
+		if _, err := loadTokens(logger, writeTokens(t, content)); err == nil {
+			t.Fatalf("expected error for %q", content)
+		}
+	}
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hub := stream.NewHub(logger, stream.Options{})
+	server, err := NewServer(logger, "", hub, Options{TokensFile: writeTokens(t, "dashboard:s3cret\n")})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	go func() {
+		hub.Run(ctx)
+		done <- struct{}{}
+	}()
+	go func() {
+		_ = server.Serve(ctx, listener)
+		done <- struct{}{}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		<-done
+	})
+	base := "http://" + listener.Addr().String()
+
+	status := func(path string, header http.Header) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, base+path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := status("/healthz", nil); code != http.StatusOK {
+		t.Fatalf("healthz should stay open, got %d", code)
+	}
+	if code := status("/metrics", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", code)
+	}
+	if code := status("/metrics", http.Header{"Authorization": {"Bearer wrong"}}); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", code)
+	}
+	if code := status("/metrics", http.Header{"Authorization": {"Bearer s3cret"}}); code != http.StatusOK {
+		t.Fatalf("expected bearer token to be accepted, got %d", code)
+	}
+	if code := status("/metrics", http.Header{"X-Api-Key": {"s3cret"}}); code != http.StatusOK {
+		t.Fatalf("expected API key to be accepted, got %d", code)
+	}
+	if code := status("/metrics?token=s3cret", nil); code != http.StatusOK {
+		t.Fatalf("expected query token to be accepted, got %d", code)
+	}
+
+	ws := "ws" + strings.TrimPrefix(base, "http") + "/ws"
+	if _, resp, err := websocket.DefaultDialer.Dial(ws, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the upgrade to be refused with 401, got %v", err)
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{"json", "token.s3cret"}}
+	conn, resp, err := dialer.Dial(ws, nil)
+	if err != nil {
+		t.Fatalf("dial with token subprotocol: %v", err)
+	}
+	conn.Close()
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "json" {
+		t.Fatalf("the token must not be echoed back, got %q", protocol)
+	}
+}
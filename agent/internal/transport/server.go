@@ -2,6 +2,8 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net"
@@ -15,18 +17,50 @@ type Server struct {
 	hub    *stream.Hub
 	logger *slog.Logger
 	srv    *http.Server
+	tls    *tls.Config
 }
 
-func NewServer(logger *slog.Logger, listenAddr string, hub *stream.Hub) *Server {
+// Options secures the server. The zero value serves plain HTTP to anyone.
+type Options struct {
+	TLS TLSOptions
+	// TokensFile lists the tokens accepted on every endpoint but /healthz.
+	TokensFile string
+}
+
+func NewServer(logger *slog.Logger, listenAddr string, hub *stream.Hub, opts Options) (*Server, error) {
 	mux := http.NewServeMux()
 	s := &Server{
 		hub:    hub,
 		logger: logger,
 	}
+	if opts.TLS.enabled() {
+		reloader, err := newCertReloader(logger, opts.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		s.tls = reloader.config()
+	}
+	protect := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if opts.TokensFile != "" {
+		auth, err := loadTokens(logger, opts.TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth tokens: %w", err)
+		}
+		protect = auth.require
+	}
 
-	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/ws", protect(func(w http.ResponseWriter, r *http.Request) {
 		s.hub.ServeWS(w, r)
-	})
+	}))
+
+	mux.HandleFunc("/events", protect(func(w http.ResponseWriter, r *http.Request) {
+		s.hub.ServeSSE(w, r)
+	}))
+
+	mux.HandleFunc("/metrics", protect(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.hub.Metrics())
+	}))
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -40,7 +74,7 @@ func NewServer(logger *slog.Logger, listenAddr string, hub *stream.Hub) *Server
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	return s
+	return s, nil
 }
 
 func (s *Server) Run(ctx context.Context) error {
@@ -48,7 +82,11 @@ func (s *Server) Run(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("listen: %w", err)
 	}
+	return s.Serve(ctx, l)
+}
 
+// Serve accepts connections on l until ctx is done.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
 	go func() {
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -56,7 +94,10 @@ func (s *Server) Run(ctx context.Context) error {
 		_ = s.srv.Shutdown(shutdownCtx)
 	}()
 
-	s.logger.Info("websocket server listening", slog.String("addr", l.Addr().String()))
+	if s.tls != nil {
+		l = tls.NewListener(l, s.tls)
+	}
+	s.logger.Info("websocket server listening", slog.String("addr", l.Addr().String()), slog.Bool("tls", s.tls != nil))
 	if err := s.srv.Serve(l); err != nil && err != http.ErrServerClosed {
 		return err
 	}
@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadCheckInterval bounds how often handshakes look for changed files.
+const reloadCheckInterval = 5 * time.Second
+
+// TLSOptions configures TLS on the listener. Without CertFile the server speaks
+// plain HTTP; with ClientCAFile clients must present a certificate it signed.
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+func (o TLSOptions) enabled() bool {
+	return o.CertFile != ""
+}
+
+// certReloader serves the certificate and client CA from disk, loading them
+// again when their files change so renewals need no restart.
+type certReloader struct {
+	opts     TLSOptions
+	logger   *slog.Logger
+	interval time.Duration
+
+	mu        sync.Mutex
+	checked   time.Time
+	modTimes  map[string]time.Time
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func newCertReloader(logger *slog.Logger, opts TLSOptions) (*certReloader, error) {
+	r := &certReloader{opts: opts, logger: logger, interval: reloadCheckInterval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+// load reads every file, replacing what is served only if all of them are
+// valid.
+func (r *certReloader) load() error {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("load client ca: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("load client ca: no certificates found in " + r.opts.ClientCAFile)
+		}
+	}
+
+	r.modTimes = modTimes
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		// A file that is missing for a moment is likely being replaced.
+		if err == nil && !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns what to serve, reloading it first when a file changed. A
+// reload that fails keeps the previous certificate until the files are fixed.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) >= r.interval {
+		r.checked = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				r.logger.Warn("failed to reload TLS certificate", slog.String("error", err.Error()))
+			} else {
+				r.logger.Info("reloaded TLS certificate", slog.String("cert", r.opts.CertFile))
+			}
+		}
+	}
+	return r.cert, r.clientCAs
+}
+
+func (r *certReloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := r.current()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				// WebSocket upgrades need HTTP/1.1.
+				NextProtos: []string{"http/1.1"},
+			}
+			if clientCAs != nil {
+				cfg.ClientCAs = clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
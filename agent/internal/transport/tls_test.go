@@ -0,0 +1,180 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/stream"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate signed by parent, or self-signed when parent is
+// nil.
+func issue(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if keyFile == "" {
+		return
+	}
+	der, _ := x509.MarshalECPrivateKey(c.key)
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+}
+
+func (c *testCert) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func startTLSServer(t *testing.T, opts TLSOptions) string {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server, err := NewServer(logger, "", stream.NewHub(logger, stream.Options{}), Options{TLS: opts})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = server.Serve(ctx, listener)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return "https://" + listener.Addr().String() + "/healthz"
+}
+
+func get(url string, roots *x509.CertPool, client *testCert) (*http.Response, error) {
+	config := &tls.Config{RootCAs: roots}
+	if client != nil {
+		config.Certificates = []tls.Certificate{client.tls()}
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: config}, Timeout: 5 * time.Second}
+	resp, err := httpClient.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestServerRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	opts := TLSOptions{
+		CertFile:     filepath.Join(dir, "agent.pem"),
+		KeyFile:      filepath.Join(dir, "agent-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	issue(t, "agent", ca).write(t, opts.CertFile, opts.KeyFile)
+	ca.write(t, opts.ClientCAFile, "")
+	url := startTLSServer(t, opts)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if resp, err := get(url, roots, issue(t, "dashboard", ca)); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected client with certificate to be served: %v", err)
+	}
+	if _, err := get(url, roots, nil); err == nil {
+		t.Fatalf("expected client without certificate to be rejected")
+	}
+	if _, err := get(url, roots, issue(t, "stranger", issue(t, "other ca", nil))); err == nil {
+		t.Fatalf("expected client with untrusted certificate to be rejected")
+	}
+}
+
+func TestCertReloaderPicksUpRenewedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	opts := TLSOptions{CertFile: filepath.Join(dir, "agent.pem"), KeyFile: filepath.Join(dir, "agent-key.pem")}
+	first := issue(t, "agent", ca)
+	first.write(t, opts.CertFile, opts.KeyFile)
+
+	reloader, err := newCertReloader(slog.New(slog.NewTextHandler(io.Discard, nil)), opts)
+	if err != nil {
+		t.Fatalf("newCertReloader: %v", err)
+	}
+	reloader.interval = 0
+
+	renewed := issue(t, "agent", ca)
+	renewed.write(t, opts.CertFile, opts.KeyFile)
+	// Filesystems with coarse timestamps may not see the rewrite otherwise.
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{opts.CertFile, opts.KeyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	if cert, _ := reloader.current(); !cert.Leaf.Equal(renewed.cert) {
+		t.Fatalf("expected the renewed certificate to be served")
+	}
+
+	// A broken renewal keeps the previous certificate.
+	if err := os.WriteFile(opts.KeyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if err := os.Chtimes(opts.KeyFile, later.Add(time.Minute), later.Add(time.Minute)); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if cert, _ := reloader.current(); !cert.Leaf.Equal(renewed.cert) {
+		t.Fatalf("expected the previous certificate to be kept")
+	}
+}
@@ -3,17 +3,116 @@ package types
 import "time"
 
 type AgentMetricsSummary struct {
-	CPUPct   float64 `json:"cpu_pct"`
-	MemBytes uint64  `json:"mem_bytes"`
+	CPUPct          float64 `json:"cpu_pct"`
+	CPUHostPct      float64 `json:"cpu_host_pct"`
+	CPUCores        float64 `json:"cpu_cores"`
+	MemBytes        uint64  `json:"mem_bytes"`
+	MemUsageBytes   uint64  `json:"mem_usage_bytes"`
+	MemCacheBytes   uint64  `json:"mem_cache_bytes"`
+	BlkioReadBytes  uint64  `json:"blkio_read_bytes"`
+	BlkioWriteBytes uint64  `json:"blkio_write_bytes"`
+	BlkioReadOps    uint64  `json:"blkio_read_ops"`
+	BlkioWriteOps   uint64  `json:"blkio_write_ops"`
+
+	NetRxBytesPerSec   float64 `json:"net_rx_bytes_per_sec"`
+	NetTxBytesPerSec   float64 `json:"net_tx_bytes_per_sec"`
+	NetRxPacketsPerSec float64 `json:"net_rx_packets_per_sec"`
+	NetTxPacketsPerSec float64 `json:"net_tx_packets_per_sec"`
+}
+
+type NetworkInterfaceSample struct {
+	Name            string  `json:"name"`
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+	RxErrorsPerSec  float64 `json:"rx_errors_per_sec"`
+	TxErrorsPerSec  float64 `json:"tx_errors_per_sec"`
+	RxDroppedPerSec float64 `json:"rx_dropped_per_sec"`
+	TxDroppedPerSec float64 `json:"tx_dropped_per_sec"`
+}
+
+type PortMapping struct {
+	IP          string `json:"ip,omitempty"`
+	PrivatePort uint16 `json:"private_port"`
+	PublicPort  uint16 `json:"public_port"`
+	Protocol    string `json:"protocol"`
 }
 
 type ContainerResourceSample struct {
-	ID            string  `json:"id"`
-	Name          string  `json:"name"`
-	CPUPct        float64 `json:"cpu_pct"`
-	MemBytes      uint64  `json:"mem_bytes"`
-	MemLimitBytes uint64  `json:"mem_limit_bytes"`
-	NetIOBytes    uint64  `json:"net_io_bytes"`
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	CPUPct          float64 `json:"cpu_pct"`
+	CPUHostPct      float64 `json:"cpu_host_pct"`
+	CPUCores        float64 `json:"cpu_cores"`
+	OnlineCPUs      uint32  `json:"online_cpus"`
+	MemBytes        uint64  `json:"mem_bytes"`
+	MemLimitBytes   uint64  `json:"mem_limit_bytes"`
+	MemUsageBytes   uint64  `json:"mem_usage_bytes"`
+	MemRSSBytes     uint64  `json:"mem_rss_bytes"`
+	MemCacheBytes   uint64  `json:"mem_cache_bytes"`
+	MemSwapBytes    uint64  `json:"mem_swap_bytes"`
+	MemKernelBytes  uint64  `json:"mem_kernel_bytes"`
+	NetIOBytes      uint64  `json:"net_io_bytes"`
+	BlkioReadBytes  uint64  `json:"blkio_read_bytes"`
+	BlkioWriteBytes uint64  `json:"blkio_write_bytes"`
+	BlkioReadOps    uint64  `json:"blkio_read_ops"`
+	BlkioWriteOps   uint64  `json:"blkio_write_ops"`
+	PIDs            uint64  `json:"pids"`
+
+	NetRxBytesPerSec   float64                  `json:"net_rx_bytes_per_sec"`
+	NetTxBytesPerSec   float64                  `json:"net_tx_bytes_per_sec"`
+	NetRxPacketsPerSec float64                  `json:"net_rx_packets_per_sec"`
+	NetTxPacketsPerSec float64                  `json:"net_tx_packets_per_sec"`
+	NetRxErrorsPerSec  float64                  `json:"net_rx_errors_per_sec"`
+	NetTxErrorsPerSec  float64                  `json:"net_tx_errors_per_sec"`
+	NetRxDroppedPerSec float64                  `json:"net_rx_dropped_per_sec"`
+	NetTxDroppedPerSec float64                  `json:"net_tx_dropped_per_sec"`
+	NetInterfaces      []NetworkInterfaceSample `json:"net_interfaces,omitempty"`
+
+	Image        string            `json:"image,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	State        string            `json:"state,omitempty"`
+	Health       string            `json:"health,omitempty"`
+	RestartCount int               `json:"restart_count"`
+	UptimeSecs   uint64            `json:"uptime_secs"`
+	Ports        []PortMapping     `json:"ports,omitempty"`
+}
+
+type GroupSummary struct {
+	Name             string  `json:"name"`
+	Project          string  `json:"project,omitempty"`
+	LabelKey         string  `json:"label_key,omitempty"`
+	Containers       int     `json:"containers"`
+	CPUPct           float64 `json:"cpu_pct"`
+	CPUHostPct       float64 `json:"cpu_host_pct"`
+	MemBytes         uint64  `json:"mem_bytes"`
+	NetRxBytesPerSec float64 `json:"net_rx_bytes_per_sec"`
+	NetTxBytesPerSec float64 `json:"net_tx_bytes_per_sec"`
+}
+
+type DiskUsage struct {
+	Mount          string `json:"mount"`
+	Device         string `json:"device"`
+	FSType         string `json:"fs_type"`
+	TotalBytes     uint64 `json:"total_bytes"`
+	UsedBytes      uint64 `json:"used_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+}
+
+type HostMetrics struct {
+	CPUPct            float64     `json:"cpu_pct"`
+	CPUs              int         `json:"cpus"`
+	MemTotalBytes     uint64      `json:"mem_total_bytes"`
+	MemUsedBytes      uint64      `json:"mem_used_bytes"`
+	MemAvailableBytes uint64      `json:"mem_available_bytes"`
+	SwapTotalBytes    uint64      `json:"swap_total_bytes"`
+	SwapUsedBytes     uint64      `json:"swap_used_bytes"`
+	Load1             float64     `json:"load1"`
+	Load5             float64     `json:"load5"`
+	Load15            float64     `json:"load15"`
+	UptimeSecs        uint64      `json:"uptime_secs"`
+	Disks             []DiskUsage `json:"disks,omitempty"`
 }
 
 type ContainerStatsBatch struct {
@@ -24,6 +123,31 @@ type ContainerStatsBatch struct {
 	Sequence     uint64                    `json:"sequence"`
 	Containers   []ContainerResourceSample `json:"containers"`
 	AgentMetrics AgentMetricsSummary       `json:"agent_metrics"`
+
+	ComposeProjects []GroupSummary `json:"compose_projects,omitempty"`
+	ComposeServices []GroupSummary `json:"compose_services,omitempty"`
+	LabelGroups     []GroupSummary `json:"label_groups,omitempty"`
+	Host            *HostMetrics   `json:"host,omitempty"`
+}
+
+// ContainerStatsDelta carries only the containers that changed since the batch
+// at BaseSequence, plus the IDs of containers that went away. Containers holds
+// complete samples that replace the client's copy.
+type ContainerStatsDelta struct {
+	Type         string                    `json:"type"`
+	AgentID      string                    `json:"agent_id"`
+	AgentLabel   string                    `json:"agent_label,omitempty"`
+	SentAt       time.Time                 `json:"sent_at"`
+	Sequence     uint64                    `json:"sequence"`
+	BaseSequence uint64                    `json:"base_sequence"`
+	Containers   []ContainerResourceSample `json:"containers"`
+	Removed      []string                  `json:"removed,omitempty"`
+	AgentMetrics AgentMetricsSummary       `json:"agent_metrics"`
+
+	ComposeProjects []GroupSummary `json:"compose_projects,omitempty"`
+	ComposeServices []GroupSummary `json:"compose_services,omitempty"`
+	LabelGroups     []GroupSummary `json:"label_groups,omitempty"`
+	Host            *HostMetrics   `json:"host,omitempty"`
 }
 
 type AgentStatusMessage struct {
@@ -36,4 +160,32 @@ type AgentStatusMessage struct {
 	Features   []string  `json:"features,omitempty"`
 }
 
+type ContainerEventMessage struct {
+	Type          string    `json:"type"`
+	AgentID       string    `json:"agent_id"`
+	AgentLabel    string    `json:"agent_label,omitempty"`
+	SentAt        time.Time `json:"sent_at"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	ContainerID   string    `json:"container_id"`
+	ContainerName string    `json:"container_name"`
+	Action        string    `json:"action"`
+	ExitCode      *int      `json:"exit_code,omitempty"`
+	HealthStatus  string    `json:"health_status,omitempty"`
+	PreviousName  string    `json:"previous_name,omitempty"`
+	// Labels holds the allowlisted container labels, as on samples.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Sequence is the batch the event followed. Clients resuming from that
+	// sequence are sent the event again.
+	Sequence uint64 `json:"sequence"`
+}
+
+// ControlReply answers a client control message: Type is "ack" or "error" and
+// ID echoes the request's ID.
+type ControlReply struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Request string `json:"request"`
+	Error   string `json:"error,omitempty"`
+}
+
 type DashboardMessage interface{}
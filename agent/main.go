@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,7 +16,9 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/your-org/docker-stats-dashboard/agent/internal/config"
+	"github.com/your-org/docker-stats-dashboard/agent/internal/host"
 	"github.com/your-org/docker-stats-dashboard/agent/internal/logging"
+	"github.com/your-org/docker-stats-dashboard/agent/internal/simulate"
 	"github.com/your-org/docker-stats-dashboard/agent/internal/stats"
 	"github.com/your-org/docker-stats-dashboard/agent/internal/stream"
 	"github.com/your-org/docker-stats-dashboard/agent/internal/transport"
@@ -45,6 +47,12 @@ func run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	return serve(ctx, cfg, logger, nil)
+}
+
+// serve wires the collector, hub and HTTP server for cfg and blocks until ctx is
+// done. A non-nil listener is used instead of cfg.ListenAddr.
+func serve(ctx context.Context, cfg config.Config, logger *slog.Logger, listener net.Listener) error {
 	hostName, err := os.Hostname()
 	if err != nil || hostName == "" {
 		hostName = "unknown-host"
@@ -55,20 +63,82 @@ func run() error {
 		agentLabel = hostName
 	}
 
-	cli, err := client.NewClientWithOpts(
-		client.WithHost(cfg.DockerEndpoint),
-		client.WithAPIVersionNegotiation(),
-	)
-	if err != nil {
-		return fmt.Errorf("docker client: %w", err)
+	var source stats.Source
+	var simulator *simulate.Simulator
+	if cfg.Source == "simulate" {
+		fake := stats.NewFakeSource()
+		simulator = simulate.New(fake, simulate.Options{
+			Containers: cfg.SimulateContainers,
+			Interval:   cfg.PollInterval,
+			Seed:       cfg.SimulateSeed,
+		})
+		source = fake
+		logger.Info("simulating containers", slog.Int("containers", cfg.SimulateContainers))
+	} else {
+		cli, err := client.NewClientWithOpts(
+			client.WithHost(cfg.DockerEndpoint),
+			client.WithAPIVersionNegotiation(),
+		)
+		if err != nil {
+			return fmt.Errorf("docker client: %w", err)
+		}
+		defer cli.Close()
+		source = stats.NewDockerSource(cli)
+	}
+
+	var hostReader stats.HostReader
+	if cfg.HostMetrics {
+		hostReader = host.NewReader(cfg.HostProc, cfg.HostRoot)
+	}
+
+	var cgroupReader *stats.CgroupReader
+	if cfg.Source == "cgroup" {
+		var err error
+		cgroupReader, err = stats.NewCgroupReader(cfg.CgroupRoot, cfg.HostProc)
+		if err != nil {
+			return fmt.Errorf("cgroup source: %w", err)
+		}
 	}
-	defer cli.Close()
 
-	collector := stats.NewCollector(cli, logger.With(slog.String("component", "collector")), cfg.PollInterval, hostName, agentLabel, cfg.WorkerLimit)
-	hub := stream.NewHub(logger.With(slog.String("component", "hub")))
-	server := transport.NewServer(logger.With(slog.String("component", "http")), cfg.ListenAddr, hub)
+	collector := stats.NewCollector(source, logger.With(slog.String("component", "collector")), stats.Options{
+		PollInterval:       cfg.PollInterval,
+		AgentID:            hostName,
+		AgentLabel:         agentLabel,
+		WorkerLimit:        cfg.WorkerLimit,
+		NetPerInterface:    cfg.NetPerInterface,
+		StatsMode:          cfg.StatsMode,
+		ReconcileInterval:  cfg.ReconcileInterval,
+		LabelAllowlist:     cfg.LabelAllowlist,
+		ComposeRollups:     cfg.ComposeRollups,
+		GroupByLabels:      cfg.GroupByLabels,
+		Host:               hostReader,
+		Cgroup:             cgroupReader,
+		FlushNewContainers: cfg.FlushNew,
+	})
+	hub := stream.NewHub(logger.With(slog.String("component", "hub")), stream.Options{
+		DeltaEpsilon:         cfg.DeltaEpsilon,
+		KeyframeInterval:     cfg.KeyframeInterval,
+		ReplayBatches:        cfg.ReplayBuffer,
+		LastBatch:            collector.LastBatch,
+		SlowClientPolicy:     cfg.SlowClientPolicy,
+		ClientQueue:          cfg.ClientQueue,
+		CompressionLevel:     cfg.CompressionLevel,
+		CompressionThreshold: cfg.CompressThreshold,
+	})
+	server, err := transport.NewServer(logger.With(slog.String("component", "http")), cfg.ListenAddr, hub, transport.Options{
+		TLS: transport.TLSOptions{
+			CertFile:     cfg.TLSCert,
+			KeyFile:      cfg.TLSKey,
+			ClientCAFile: cfg.TLSClientCA,
+		},
+		TokensFile: cfg.AuthTokensFile,
+	})
+	if err != nil {
+		return err
+	}
 
 	statsCh := make(chan types.ContainerStatsBatch, 64)
+	eventsCh := make(chan types.ContainerEventMessage, 64)
 	startedAt := time.Now()
 
 	g, ctx := errgroup.WithContext(ctx)
@@ -78,17 +148,27 @@ func run() error {
 		return nil
 	})
 
+	if simulator != nil {
+		g.Go(func() error {
+			simulator.Run(ctx)
+			return nil
+		})
+	}
+
 	g.Go(func() error {
-		collector.Collect(ctx, statsCh)
+		collector.Collect(ctx, statsCh, eventsCh)
 		return nil
 	})
 
 	g.Go(func() error {
+		if listener != nil {
+			return server.Serve(ctx, listener)
+		}
 		return server.Run(ctx)
 	})
 
 	g.Go(func() error {
-		return dispatchLoop(ctx, logger, hub, statsCh, startedAt, hostName, agentLabel)
+		return dispatchLoop(ctx, logger, hub, statsCh, eventsCh, startedAt, hostName, agentLabel)
 	})
 
 	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
@@ -103,6 +183,7 @@ func dispatchLoop(
 	logger *slog.Logger,
 	hub *stream.Hub,
 	statsCh <-chan types.ContainerStatsBatch,
+	eventsCh <-chan types.ContainerEventMessage,
 	startedAt time.Time,
 	agentID string,
 	agentLabel string,
@@ -119,18 +200,13 @@ func dispatchLoop(
 			SentAt:     time.Now().UTC(),
 			UptimeSecs: uptime,
 			Version:    version,
-			Features:   []string{"container_stats"},
-		}
-		payload, err := json.Marshal(status)
-		if err != nil {
-			logger.Warn("failed to marshal agent status", slog.String("error", err.Error()))
-			return
+			Features:   []string{"container_stats", "container_stats_delta", "container_events", "client_control", "msgpack", "cbor", "server_sent_events"},
 		}
 		logger.Debug("dispatching agent status",
 			slog.Time("sent_at", status.SentAt),
 			slog.Uint64("uptime_secs", status.UptimeSecs),
 		)
-		hub.Broadcast(payload)
+		hub.PublishStatus(status)
 	}
 
 	sendStatus()
@@ -140,17 +216,18 @@ func dispatchLoop(
 		case <-ctx.Done():
 			return ctx.Err()
 		case batch := <-statsCh:
-			payload, err := json.Marshal(batch)
-			if err != nil {
-				logger.Warn("failed to marshal stats batch", slog.String("error", err.Error()))
-				continue
-			}
 			logger.Debug("dispatching stats batch",
 				slog.Uint64("sequence", batch.Sequence),
 				slog.Time("sent_at", batch.SentAt),
 				slog.Int("containers", len(batch.Containers)),
 			)
-			hub.Broadcast(payload)
+			hub.PublishBatch(batch)
+		case event := <-eventsCh:
+			logger.Debug("dispatching container event",
+				slog.String("container_id", event.ContainerID),
+				slog.String("action", event.Action),
+			)
+			hub.PublishEvent(event)
 		case <-statusTicker.C:
 			sendStatus()
 		}
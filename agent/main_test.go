@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/gorilla/websocket"
+
+	"github.com/your-org/docker-stats-dashboard/agent/internal/config"
+	"github.com/your-org/docker-stats-dashboard/agent/internal/dockertest"
+	"github.com/your-org/docker-stats-dashboard/agent/internal/types"
+)
+
+func testConfig(daemon *dockertest.Server) config.Config {
+	return config.Config{
+		DockerEndpoint:    daemon.Host(),
+		HostLabel:         "e2e",
+		PollInterval:      20 * time.Millisecond,
+		LogLevel:          "error",
+		WorkerLimit:       4,
+		StatsMode:         "poll",
+		ReconcileInterval: time.Minute,
+		ComposeRollups:    true,
+		Source:            "docker",
+		DeltaEpsilon:      0.01,
+		KeyframeInterval:  time.Minute,
+	}
+}
+
+// startAgent runs the full agent wiring against cfg and returns a connected
+// WebSocket client.
+func startAgent(t *testing.T, cfg config.Config) *websocket.Conn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), listener)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("agent returned error: %v", err)
+		}
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial websocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil returns the first message of the given type accepted by match.
+func readUntil[T any](t *testing.T, conn *websocket.Conn, messageType string, match func(T) bool) T {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", messageType, err)
+		}
+		var envelope struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatalf("invalid message %s: %v", data, err)
+		}
+		if envelope.Type != messageType {
+			continue
+		}
+		var msg T
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("invalid %s message: %v", messageType, err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+func findSample(batch types.ContainerStatsBatch, id string) (types.ContainerResourceSample, bool) {
+	for _, sample := range batch.Containers {
+		if sample.ID == id {
+			return sample, true
+		}
+	}
+	return types.ContainerResourceSample{}, false
+}
+
+func webContainer() docker.Container {
+	return docker.Container{
+		ID:     "c0ffee",
+		Names:  []string{"/web"},
+		Image:  "nginx:1.27",
+		State:  "running",
+		Labels: map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "web"},
+	}
+}
+
+func TestEndToEndStatsBatch(t *testing.T) {
+	daemon := dockertest.New(t)
+	daemon.APIVersion = "1.43"
+	daemon.SetContainer(webContainer())
+	daemon.SetStats("c0ffee", docker.StatsJSON{Stats: container.Stats{
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 3_000_000},
+			SystemUsage: 20_000_000,
+			OnlineCPUs:  2,
+		},
+		PreCPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 1_000_000},
+			SystemUsage: 10_000_000,
+		},
+		MemoryStats: container.MemoryStats{Usage: 64 << 20, Limit: 256 << 20},
+	}})
+
+	conn := startAgent(t, testConfig(daemon))
+
+	batch := readUntil(t, conn, "container_stats_batch", func(batch types.ContainerStatsBatch) bool {
+		_, ok := findSample(batch, "c0ffee")
+		return ok
+	})
+	sample, _ := findSample(batch, "c0ffee")
+	if sample.Name != "web" || sample.Image != "nginx:1.27" || sample.MemBytes != 64<<20 {
+		t.Fatalf("unexpected sample: %+v", sample)
+	}
+	if sample.CPUPct != 40 {
+		t.Fatalf("unexpected cpu pct: %f", sample.CPUPct)
+	}
+	if batch.AgentLabel != "e2e" || len(batch.ComposeProjects) != 1 || batch.ComposeProjects[0].Name != "shop" {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+
+	for version := range daemon.Requests() {
+		if version != "1.43" {
+			t.Fatalf("client did not negotiate the daemon version: %v", daemon.Requests())
+		}
+	}
+}
+
+func TestEndToEndLifecycleEvents(t *testing.T) {
+	daemon := dockertest.NewUnix(t)
+	conn := startAgent(t, testConfig(daemon))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for daemon.Subscribers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("agent never subscribed to events")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	daemon.Start(webContainer())
+	readUntil(t, conn, "container_event", func(event types.ContainerEventMessage) bool {
+		return event.Action == "start" && event.ContainerName == "web"
+	})
+	readUntil(t, conn, "container_stats_batch", func(batch types.ContainerStatsBatch) bool {
+		_, ok := findSample(batch, "c0ffee")
+		return ok
+	})
+
+	daemon.Stop("c0ffee", 137)
+	event := readUntil(t, conn, "container_event", func(event types.ContainerEventMessage) bool {
+		return event.Action == "die"
+	})
+	if event.ExitCode == nil || *event.ExitCode != 137 {
+		t.Fatalf("unexpected die event: %+v", event)
+	}
+	readUntil(t, conn, "container_stats_batch", func(batch types.ContainerStatsBatch) bool {
+		_, ok := findSample(batch, "c0ffee")
+		return !ok
+	})
+}
+
+func TestEndToEndStreamMode(t *testing.T) {
+	daemon := dockertest.New(t)
+	daemon.StreamInterval = 20 * time.Millisecond
+	daemon.SetContainer(webContainer())
+	daemon.SetStats("c0ffee", docker.StatsJSON{Stats: container.Stats{
+		MemoryStats: container.MemoryStats{Usage: 1 << 20, Limit: 1 << 30},
+	}})
+
+	cfg := testConfig(daemon)
+	cfg.StatsMode = "stream"
+	conn := startAgent(t, cfg)
+
+	readUntil(t, conn, "container_stats_batch", func(batch types.ContainerStatsBatch) bool {
+		sample, ok := findSample(batch, "c0ffee")
+		return ok && sample.MemBytes == 1<<20
+	})
+
+	daemon.SetStats("c0ffee", docker.StatsJSON{Stats: container.Stats{
+		MemoryStats: container.MemoryStats{Usage: 2 << 20, Limit: 1 << 30},
+	}})
+	readUntil(t, conn, "container_stats_batch", func(batch types.ContainerStatsBatch) bool {
+		sample, ok := findSample(batch, "c0ffee")
+		return ok && sample.MemBytes == 2<<20
+	})
+}
@@ -65,7 +65,8 @@ function createBatch(options: {
 		sequence,
 		containers,
 		agent_metrics: {
-			cpu_pct: Number(Math.min(totalCpu / Math.max(containerCount, 1), 100).toFixed(1)),
+			cpu_pct: Number(totalCpu.toFixed(1)),
+			cpu_host_pct: Number(Math.min(totalCpu / Math.max(containerCount, 1), 100).toFixed(1)),
 			mem_bytes: totalMem
 		}
 	};
@@ -50,6 +50,8 @@ class AgentConnection {
   private ws: WebSocket | null = null;
   private reconnectTimer: NodeJS.Timeout | null = null;
   private stopped = false;
+  // Sequence of the last batch received, so a reconnect can resume from it.
+  private lastSequence: number | null = null;
 
   constructor(private cfg: AgentConfigResolved, private emit: (e: AgentConnectionEvent) => void) {
     this.connect();
@@ -59,7 +61,7 @@ class AgentConnection {
     if (this.stopped) return;
     //console.log('[AgentHub] connecting ->', this.cfg.id, this.cfg.url);
     this.emit({ type: 'agent_status', agent_id: this.cfg.id, status: 'connecting', label: this.cfg.label, at: new Date().toISOString() });
-    this.ws = new WebSocket(this.cfg.url);
+    this.ws = new WebSocket(this.resumeUrl());
 
     this.ws.on('open', () => {
       //console.log('[AgentHub] connected  ->', this.cfg.id);
@@ -72,6 +74,9 @@ class AgentConnection {
         const batchType = parsed?.type ?? 'unknown';
         //console.log('[AgentHub] message   <-', this.cfg.id, batchType);
         if (batchType === 'container_stats_batch') {
+          if (typeof parsed.sequence === 'number') {
+            this.lastSequence = parsed.sequence;
+          }
           this.emit({
             type: 'container_stats_batch',
             agent_id: this.cfg.id,
@@ -108,6 +113,17 @@ class AgentConnection {
     });
   }
 
+  private resumeUrl(): string {
+    if (this.lastSequence === null) return this.cfg.url;
+    try {
+      const url = new URL(this.cfg.url);
+      url.searchParams.set('since', String(this.lastSequence));
+      return url.toString();
+    } catch {
+      return this.cfg.url;
+    }
+  }
+
   private scheduleReconnect() {
     if (this.stopped) return;
     if (this.reconnectTimer) return;
@@ -160,7 +176,10 @@ export class AgentHub extends EventEmitter {
 
     if (isContainerStatsBatch(event.payload)) {
       const payload = event.payload as ContainerStatsBatch;
-      const cpu = Number(payload.agent_metrics?.cpu_pct);
+      // cpu_pct sums docker-style percentages and can exceed 100; agents that
+      // predate cpu_host_pct reported it clamped.
+      const metrics = payload.agent_metrics;
+      const cpu = Number(metrics?.cpu_host_pct ?? metrics?.cpu_pct);
       if (Number.isFinite(cpu)) {
         const rawTimestamp = typeof payload.sent_at === 'string' ? payload.sent_at : event.received_at;
         const ts = new Date(rawTimestamp);
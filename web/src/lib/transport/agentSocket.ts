@@ -27,7 +27,7 @@ export function connectAgentSocket(endpoint: string, handlers: AgentSocketHandle
 
 			if (payload.type === 'container_stats_batch') {
 				handlers.onStats(payload as ContainerStatsBatch);
-			} else {
+			} else if (payload.type === 'agent_status') {
 				handlers.onStatus(payload as AgentStatusMessage);
 			}
 		} catch (error) {
@@ -12,7 +12,19 @@ export interface AgentEndpoint {
 
 export interface AgentMetricsSummary {
         cpu_pct: number;
+	cpu_host_pct?: number;
+	cpu_cores?: number;
         mem_bytes: number;
+	mem_usage_bytes?: number;
+	mem_cache_bytes?: number;
+	blkio_read_bytes?: number;
+	blkio_write_bytes?: number;
+	blkio_read_ops?: number;
+	blkio_write_ops?: number;
+	net_rx_bytes_per_sec?: number;
+	net_tx_bytes_per_sec?: number;
+	net_rx_packets_per_sec?: number;
+	net_tx_packets_per_sec?: number;
 }
 
 export interface AgentCpuSample {
@@ -20,13 +32,97 @@ export interface AgentCpuSample {
         cpu_pct: number;
 }
 
+export interface NetworkInterfaceSample {
+	name: string;
+	rx_bytes_per_sec: number;
+	tx_bytes_per_sec: number;
+	rx_packets_per_sec: number;
+	tx_packets_per_sec: number;
+	rx_errors_per_sec: number;
+	tx_errors_per_sec: number;
+	rx_dropped_per_sec: number;
+	tx_dropped_per_sec: number;
+}
+
+export interface PortMapping {
+	ip?: string;
+	private_port: number;
+	public_port: number;
+	protocol: string;
+}
+
 export interface ContainerResourceSample {
         id: string;
         name: string;
         cpu_pct: number;
+	cpu_host_pct?: number;
+	cpu_cores?: number;
+	online_cpus?: number;
         mem_bytes: number;
 	mem_limit_bytes: number;
+	mem_usage_bytes?: number;
+	mem_rss_bytes?: number;
+	mem_cache_bytes?: number;
+	mem_swap_bytes?: number;
+	mem_kernel_bytes?: number;
 	net_io_bytes: number;
+	blkio_read_bytes?: number;
+	blkio_write_bytes?: number;
+	blkio_read_ops?: number;
+	blkio_write_ops?: number;
+	pids?: number;
+	net_rx_bytes_per_sec?: number;
+	net_tx_bytes_per_sec?: number;
+	net_rx_packets_per_sec?: number;
+	net_tx_packets_per_sec?: number;
+	net_rx_errors_per_sec?: number;
+	net_tx_errors_per_sec?: number;
+	net_rx_dropped_per_sec?: number;
+	net_tx_dropped_per_sec?: number;
+	net_interfaces?: NetworkInterfaceSample[];
+	image?: string;
+	labels?: Record<string, string>;
+	state?: string;
+	health?: string;
+	restart_count?: number;
+	uptime_secs?: number;
+	ports?: PortMapping[];
+}
+
+export interface GroupSummary {
+	name: string;
+	project?: string;
+	label_key?: string;
+	containers: number;
+	cpu_pct: number;
+	cpu_host_pct: number;
+	mem_bytes: number;
+	net_rx_bytes_per_sec: number;
+	net_tx_bytes_per_sec: number;
+}
+
+export interface DiskUsage {
+	mount: string;
+	device: string;
+	fs_type: string;
+	total_bytes: number;
+	used_bytes: number;
+	available_bytes: number;
+}
+
+export interface HostMetrics {
+	cpu_pct: number;
+	cpus: number;
+	mem_total_bytes: number;
+	mem_used_bytes: number;
+	mem_available_bytes: number;
+	swap_total_bytes: number;
+	swap_used_bytes: number;
+	load1: number;
+	load5: number;
+	load15: number;
+	uptime_secs: number;
+	disks?: DiskUsage[];
 }
 
 export interface ContainerStatsBatch {
@@ -37,6 +133,26 @@ export interface ContainerStatsBatch {
 	sequence: number;
 	containers: ContainerResourceSample[];
 	agent_metrics: AgentMetricsSummary;
+	compose_projects?: GroupSummary[];
+	compose_services?: GroupSummary[];
+	label_groups?: GroupSummary[];
+	host?: HostMetrics;
+}
+
+export interface ContainerStatsDelta {
+	type: 'container_stats_delta';
+	agent_id: string;
+	agent_label?: string;
+	sent_at: string;
+	sequence: number;
+	base_sequence: number;
+	containers: ContainerResourceSample[];
+	removed?: string[];
+	agent_metrics: AgentMetricsSummary;
+	compose_projects?: GroupSummary[];
+	compose_services?: GroupSummary[];
+	label_groups?: GroupSummary[];
+	host?: HostMetrics;
 }
 
 export interface AgentStatusMessage {
@@ -49,4 +165,44 @@ export interface AgentStatusMessage {
 	features?: string[];
 }
 
-export type DashboardMessage = ContainerStatsBatch | AgentStatusMessage;
+export type ContainerEventAction =
+	| 'start'
+	| 'stop'
+	| 'die'
+	| 'oom'
+	| 'restart'
+	| 'health_status'
+	| 'rename'
+	| 'pause'
+	| 'unpause'
+	| 'destroy';
+
+export interface ContainerEventMessage {
+	type: 'container_event';
+	agent_id: string;
+	agent_label?: string;
+	sent_at: string;
+	occurred_at: string;
+	container_id: string;
+	container_name: string;
+	action: ContainerEventAction;
+	exit_code?: number;
+	health_status?: string;
+	previous_name?: string;
+	labels?: Record<string, string>;
+	sequence?: number;
+}
+
+export interface ControlReply {
+	type: 'ack' | 'error';
+	id?: string;
+	request: string;
+	error?: string;
+}
+
+export type DashboardMessage =
+	| ContainerStatsBatch
+	| ContainerStatsDelta
+	| AgentStatusMessage
+	| ContainerEventMessage
+	| ControlReply;